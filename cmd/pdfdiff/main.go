@@ -0,0 +1,71 @@
+// pdfdiff prints the structural differences between two PDF files, one per
+// line. It exits with status 1 if the files differ and 2 if either can't be
+// parsed.
+//
+//	pdfdiff [-ignore-numbers] [-ignore-key-order] [-tolerance 0.001] a.pdf b.pdf
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rgracey/pdf"
+	"github.com/rgracey/pdf/pkg/ast"
+)
+
+func main() {
+	ignoreNumbers := flag.Bool("ignore-numbers", false, "ignore object numbering")
+	ignoreKeyOrder := flag.Bool("ignore-key-order", false, "ignore the order of dictionary keys")
+	tolerance := flag.Float64("tolerance", -1, "treat numbers within this tolerance as equal")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] a.pdf b.pdf\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	options := []ast.EqualOption{}
+
+	if *ignoreNumbers {
+		options = append(options, ast.IgnoreObjectNumbers())
+	}
+
+	if *ignoreKeyOrder {
+		options = append(options, ast.IgnoreKeyOrder())
+	}
+
+	if *tolerance >= 0 {
+		options = append(options, ast.IgnoreFloatPrecision(*tolerance))
+	}
+
+	a, err := pdf.ParseFile(flag.Arg(0))
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", flag.Arg(0), err)
+		os.Exit(2)
+	}
+
+	b, err := pdf.ParseFile(flag.Arg(1))
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", flag.Arg(1), err)
+		os.Exit(2)
+	}
+
+	differences := ast.Diff(a, b, options...)
+
+	for _, difference := range differences {
+		fmt.Println(difference)
+	}
+
+	if len(differences) > 0 {
+		os.Exit(1)
+	}
+}
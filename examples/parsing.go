@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 
@@ -17,5 +18,11 @@ func main() {
 
 	ast, _ := pdf.ParseStream(file)
 
-	fmt.Printf("%+v", ast.Children())
+	data, err := json.MarshalIndent(ast, "", "  ")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(string(data))
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/document"
 	"github.com/rgracey/pdf/pkg/parser"
 	"github.com/rgracey/pdf/pkg/serialiser"
 	"github.com/rgracey/pdf/pkg/tokeniser"
@@ -13,7 +14,7 @@ import (
 func ParseStream(r io.Reader) (ast.PdfNode, error) {
 	tokeniser := tokeniser.NewTokeniser(r)
 	parser := parser.NewParser(tokeniser)
-	return parser.Parse(), nil
+	return parser.Parse()
 }
 
 func ParseFile(filename string) (ast.PdfNode, error) {
@@ -26,7 +27,26 @@ func ParseFile(filename string) (ast.PdfNode, error) {
 	return ParseStream(file)
 }
 
+// Open opens a document for random access, parsing objects only as they are
+// needed rather than parsing the whole document up front
+func Open(r io.ReaderAt, size int64) (*document.Document, error) {
+	return document.NewDocument(r, size)
+}
+
 func Serialise(node ast.PdfNode) (string, error) {
 	ser := serialiser.NewSerialiser()
 	return ser.Serialise(node)
 }
+
+// SerialiseTo serialises a node to a writer, without building up the whole
+// output in memory
+func SerialiseTo(w io.Writer, node ast.PdfNode) error {
+	ser := serialiser.NewSerialiser()
+	return ser.SerialiseTo(w, node)
+}
+
+// Update serialises an incremental update to the original document holding the
+// given new or modified objects. The update is to be appended to the original.
+func Update(original []byte, objects ...*ast.IndirectObjectNode) (string, error) {
+	return serialiser.Update(original, objects)
+}
@@ -0,0 +1,279 @@
+package pdf_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+
+	"github.com/rgracey/pdf"
+)
+
+const samplePdf = `%PDF-1.4
+%âãÏÓ
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R] /Count 1 >>
+endobj
+3 0 obj
+<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612.0 792.0] /Contents 4 0 R >>
+endobj
+4 0 obj
+<< /Length 43 >>
+stream
+BT /F1 24 Tf 100 100 Td (Hello World) Tj ET
+endstream
+endobj
+xref
+0 5
+0000000000 65535 f
+0000000019 00000 n
+0000000068 00000 n
+0000000125 00000 n
+0000000216 00000 n
+trailer
+<< /Size 5 /Root 1 0 R /ID [<8A3C2D1E> <8A3C2D1E>] >>
+startxref
+309
+%%EOF
+`
+
+func TestParseStream_ParsesSample(t *testing.T) {
+	root, err := pdf.ParseStream(strings.NewReader(samplePdf))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if root.Value() != "PDF-1.4" {
+		t.Errorf("Expected version PDF-1.4, got %v", root.Value())
+	}
+}
+
+func TestParseStream_ParsesXRefs(t *testing.T) {
+	root, err := pdf.ParseStream(strings.NewReader(samplePdf))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	xrefs := findNode(root, ast.XREFS).(*ast.XRefsNode)
+
+	if len(xrefs.Entries()) != 5 {
+		t.Errorf("Expected 5 entries, got %v", xrefs.Entries())
+	}
+
+	objects := root.(*ast.RootNode).Objects()
+
+	if mismatches := xrefs.Mismatches(objects); len(mismatches) != 0 {
+		t.Errorf("Expected no mismatches, got %v", mismatches)
+	}
+
+	// Point the entry for object 2 a byte too early
+	broken := strings.Replace(samplePdf, "0000000068", "0000000067", 1)
+	root, err = pdf.ParseStream(strings.NewReader(broken))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	xrefs = findNode(root, ast.XREFS).(*ast.XRefsNode)
+	objects = root.(*ast.RootNode).Objects()
+	mismatches := xrefs.Mismatches(objects)
+
+	if len(mismatches) != 1 || mismatches[0].Entry.Id != 2 || mismatches[0].Actual != 68 {
+		t.Errorf("Expected object 2 to be at 68 instead of 67, got %v", mismatches)
+	}
+}
+
+func TestParseStream_NewestRevisionWins(t *testing.T) {
+	update := "2 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n" +
+		"xref\n2 1\n0000000495 00000 n\n" +
+		"trailer\n<< /Size 5 /Root 1 0 R /Prev 309 >>\nstartxref\n547\n%%EOF\n"
+
+	root, err := pdf.ParseStream(strings.NewReader(samplePdf + update))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	rootNode := root.(*ast.RootNode)
+
+	if len(rootNode.Trailers()) != 2 {
+		t.Fatalf("Expected 2 trailers, got %d", len(rootNode.Trailers()))
+	}
+
+	if rootNode.GetTrailer() != rootNode.Trailers()[1] {
+		t.Errorf("Expected the newest trailer")
+	}
+
+	pages := rootNode.Object(2).Children()[0].(*ast.DictNode)
+
+	if pages.Get("Count").Value() != int64(0) {
+		t.Errorf("Expected the updated pages object, got %v", pages.Get("Count").Value())
+	}
+
+	doc, err := pdf.Open(strings.NewReader(samplePdf+update), int64(len(samplePdf+update)))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(doc.Revisions()) != 2 {
+		t.Errorf("Expected 2 revisions, got %d", len(doc.Revisions()))
+	}
+}
+
+func TestOpen_ReadsObjectsOnDemand(t *testing.T) {
+	doc, err := pdf.Open(strings.NewReader(samplePdf), int64(len(samplePdf)))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	obj, err := doc.Object(4)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	stream := obj.Children()[1].Value().([]byte)
+
+	if string(stream) != "BT /F1 24 Tf 100 100 Td (Hello World) Tj ET" {
+		t.Errorf("Unexpected stream body %q", stream)
+	}
+}
+
+func TestSerialise_RoundTripsBinaryStreams(t *testing.T) {
+	raw := []byte{}
+
+	for i := 0; i < 4096; i++ {
+		raw = append(raw, byte(i*7919%251), byte(i))
+	}
+
+	compressed := bytes.Buffer{}
+	w := zlib.NewWriter(&compressed)
+	w.Write(raw)
+	w.Close()
+
+	// Make sure the stream contains bytes that aren't valid UTF-8, the
+	// endstream keyword, and that it ends in what looks like whitespace
+	data := append(compressed.Bytes(), 0xff, 0xfe, 0x80, ' ')
+	data = append(data, "\nendstream\r"...)
+
+	input := fmt.Sprintf(
+		"%%PDF-1.7\n1 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream\nendobj\n",
+		len(data),
+		data,
+	)
+
+	root, err := pdf.ParseStream(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	serialised, err := pdf.Serialise(root)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(serialised, "stream\n"+string(data)+"\nendstream") {
+		t.Errorf("Expected serialised output to contain the original stream bytes")
+	}
+
+	reparsed, err := pdf.ParseStream(strings.NewReader(serialised))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	stream := findNode(reparsed, ast.STREAM)
+
+	if stream == nil {
+		t.Fatalf("Expected a stream node")
+	}
+
+	if !bytes.Equal(stream.Value().([]byte), data) {
+		t.Errorf("Expected stream bytes to round trip unchanged")
+	}
+}
+
+func TestParseStream_DoesNotPanicOnTruncatedInput(t *testing.T) {
+	for i := 0; i < len(samplePdf); i++ {
+		parseWithoutPanic(t, samplePdf[:i])
+	}
+}
+
+func TestParseStream_DoesNotPanicOnCorruptInput(t *testing.T) {
+	corpus := []string{
+		"",
+		"obj",
+		"R",
+		"endobj",
+		">>",
+		"]",
+		"}",
+		"1 obj",
+		"/Name 0 R",
+		"<< 1 2 3 >>",
+		"<< /A >> >>",
+		"[ << ] >>",
+		"1 0 obj << /A (unterminated",
+		"1 0 obj << /A (escape at end\\",
+		"1 0 obj << /Length 10 >> stream\nabc",
+		"trailer << /Size 1 >> endobj",
+		"xref trailer %%EOF %%EOF",
+		"%comment without newline",
+		"1 0 obj\n\n\n   ",
+		"\x00\x00\x00",
+		"\xff\xfe\xfd",
+	}
+
+	for _, input := range corpus {
+		parseWithoutPanic(t, input)
+	}
+
+	// Corrupt the sample one byte at a time
+	for i := 0; i < len(samplePdf); i++ {
+		for _, b := range []byte{'(', ')', '<', '>', '[', ']', '/', '%', 'R', 0} {
+			corrupt := []byte(samplePdf)
+			corrupt[i] = b
+			parseWithoutPanic(t, string(corrupt))
+		}
+	}
+}
+
+// parseWithoutPanic parses the input, failing the test if parsing panics
+func parseWithoutPanic(t *testing.T, input string) {
+	t.Helper()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Panic parsing %q: %v", input, r)
+		}
+	}()
+
+	pdf.ParseStream(strings.NewReader(input))
+}
+
+// findNode returns the first node of the given type in the tree
+func findNode(node ast.PdfNode, nodeType ast.Type) ast.PdfNode {
+	if node.Type() == nodeType {
+		return node
+	}
+
+	for _, child := range node.Children() {
+		if found := findNode(child, nodeType); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
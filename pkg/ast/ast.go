@@ -1,6 +1,12 @@
 // Package ast implements the abstract syntax tree for the PDF document
 package ast
 
+import (
+	"bytes"
+
+	"github.com/rgracey/pdf/pkg/filters"
+)
+
 // All nodes in the AST implement the PdfNode interface to allow for easy
 // traversal. Each node can be cast to a specific type via a type assertion to
 // access node specific methods/fields.
@@ -25,8 +31,48 @@ const (
 	INDIRECT_OBJECT
 	OBJECT_REF
 	TRAILER
+	NULL
 )
 
+func (t Type) String() string {
+	switch t {
+	case ROOT:
+		return "ROOT"
+	case BOOLEAN:
+		return "BOOLEAN"
+	case FLOAT:
+		return "FLOAT"
+	case INTEGER:
+		return "INTEGER"
+	case NAME:
+		return "NAME"
+	case DICT:
+		return "DICT"
+	case DICT_ENTRY:
+		return "DICT_ENTRY"
+	case STRING:
+		return "STRING"
+	case FUNCTION:
+		return "FUNCTION"
+	case ARRAY:
+		return "ARRAY"
+	case STREAM:
+		return "STREAM"
+	case XREFS:
+		return "XREFS"
+	case INDIRECT_OBJECT:
+		return "INDIRECT_OBJECT"
+	case OBJECT_REF:
+		return "OBJECT_REF"
+	case TRAILER:
+		return "TRAILER"
+	case NULL:
+		return "NULL"
+	}
+
+	return "UNKNOWN"
+}
+
 // PdfNode is the interface for all nodes in the AST
 type PdfNode interface {
 	Type() Type                // Get the type of the node
@@ -56,7 +102,11 @@ func (n *pdfNode) Value() interface{} {
 	return n.value
 }
 
-// SetValue sets the value of the node
+// SetValue sets the value of the node. The value must be of the type stored
+// for the node type (bool, int64, float64, string for names, []byte for
+// strings and streams, nil for null), although numbers are converted: an int
+// or int64 is accepted for a real, and an int or a whole float64 for an
+// integer. Anything else panics.
 func (n *pdfNode) SetValue(value interface{}) {
 	switch n.nodeType {
 	case BOOLEAN:
@@ -64,17 +114,43 @@ func (n *pdfNode) SetValue(value interface{}) {
 			panic("Value is not a boolean")
 		}
 	case FLOAT:
-		if _, ok := value.(float64); !ok {
+		switch number := value.(type) {
+		case float64:
+		case int64:
+			value = float64(number)
+		case int:
+			value = float64(number)
+		default:
 			panic("Value is not a float")
 		}
 	case INTEGER:
-		if _, ok := value.(int); !ok {
+		switch integer := value.(type) {
+		case int64:
+		case int:
+			value = int64(integer)
+		case float64:
+			whole, ok := wholeFloat(integer)
+
+			if !ok {
+				panic("Value is not a whole number")
+			}
+
+			value = whole
+		default:
 			panic("Value is not an integer")
 		}
-	case STRING, NAME:
+	case NAME:
 		if _, ok := value.(string); !ok {
 			panic("Value is not a string")
 		}
+	case STRING, STREAM:
+		if _, ok := value.([]byte); !ok {
+			panic("Value is not a byte slice")
+		}
+	case NULL:
+		if value != nil {
+			panic("Value is not null")
+		}
 	}
 
 	n.value = value
@@ -128,17 +204,58 @@ func NewRootNode() *RootNode {
 	}
 }
 
-// GetTrailer returns the trailer node if it exists
-func (n *RootNode) GetTrailer() *TrailerNode {
+// Objects returns all indirect objects that are children of the root, in the
+// order they appear
+func (n *RootNode) Objects() []*IndirectObjectNode {
+	objects := []*IndirectObjectNode{}
+
 	for _, child := range n.children {
-		if child.Type() == TRAILER {
-			return child.(*TrailerNode)
+		if obj, ok := child.(*IndirectObjectNode); ok {
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects
+}
+
+// Object returns the newest revision of the indirect object with the given
+// object number, or nil if there isn't one. Incrementally updated documents
+// can have several revisions of an object, with later ones replacing earlier.
+func (n *RootNode) Object(id int64) *IndirectObjectNode {
+	for i := len(n.children) - 1; i >= 0; i-- {
+		if obj, ok := n.children[i].(*IndirectObjectNode); ok && obj.Id() == id {
+			return obj
 		}
 	}
 
 	return nil
 }
 
+// GetTrailer returns the newest trailer node if it exists
+func (n *RootNode) GetTrailer() *TrailerNode {
+	trailers := n.Trailers()
+
+	if len(trailers) == 0 {
+		return nil
+	}
+
+	return trailers[len(trailers)-1]
+}
+
+// Trailers returns all trailer nodes that are children of the root, oldest
+// first. Incrementally updated documents have a trailer for each revision.
+func (n *RootNode) Trailers() []*TrailerNode {
+	trailers := []*TrailerNode{}
+
+	for _, child := range n.children {
+		if trailer, ok := child.(*TrailerNode); ok {
+			trailers = append(trailers, trailer)
+		}
+	}
+
+	return trailers
+}
+
 type BooleanNode struct {
 	*pdfNode
 }
@@ -152,6 +269,18 @@ func NewBooleanNode(value bool) *BooleanNode {
 	}
 }
 
+type NullNode struct {
+	*pdfNode
+}
+
+func NewNullNode() *NullNode {
+	return &NullNode{
+		&pdfNode{
+			nodeType: NULL,
+		},
+	}
+}
+
 type FloatNode struct {
 	*pdfNode
 }
@@ -193,17 +322,62 @@ func NewNameNode(value string) *NameNode {
 
 type StringNode struct {
 	*pdfNode
+	hex    bool
+	digits []byte // The text of a hex string as written, if known
 }
 
-func NewStringNode(value string) *StringNode {
+func NewStringNode(value []byte) *StringNode {
 	return &StringNode{
 		&pdfNode{
 			nodeType: STRING,
 			value:    value,
 		},
+		false,
+		nil,
 	}
 }
 
+// NewHexStringNode returns a string node that was (or should be) written as a
+// hexadecimal string. The value is the decoded string.
+func NewHexStringNode(value []byte) *StringNode {
+	return &StringNode{
+		&pdfNode{
+			nodeType: STRING,
+			value:    value,
+		},
+		true,
+		nil,
+	}
+}
+
+// IsHex returns true if the string is written as a hexadecimal string
+func (n *StringNode) IsHex() bool {
+	return n.hex
+}
+
+// SetHexDigits records the text a hex string was written with, between the
+// angle brackets, so that it can be written back in the same form, e.g. with
+// lowercase digits
+func (n *StringNode) SetHexDigits(digits []byte) {
+	n.digits = digits
+}
+
+// HexDigits returns the text the hex string was written with, or nil if it
+// isn't known or no longer decodes to the value of the string
+func (n *StringNode) HexDigits() []byte {
+	if !n.hex || n.digits == nil {
+		return nil
+	}
+
+	decoded, err := filters.ASCIIHexDecode(append(append([]byte{}, n.digits...), '>'))
+
+	if err != nil || !bytes.Equal(decoded, n.Value().([]byte)) {
+		return nil
+	}
+
+	return n.digits
+}
+
 type FunctionNode struct {
 	*pdfNode
 }
@@ -228,21 +402,52 @@ func NewArrayNode() *ArrayNode {
 	}
 }
 
+// StreamNode is the (encoded) data of a stream. Its dictionary is held by the
+// indirect object it belongs to, which is set when the stream is added to one.
 type StreamNode struct {
 	*pdfNode
+	object *IndirectObjectNode
 }
 
-func NewStreamNode(value string) *StreamNode {
+func NewStreamNode(value []byte) *StreamNode {
 	return &StreamNode{
 		&pdfNode{
 			nodeType: STREAM,
 			value:    value,
 		},
+		nil,
 	}
 }
 
+// XRefEntryType is the type of an entry in a cross-reference table
+type XRefEntryType int
+
+const (
+	XREF_FREE XRefEntryType = iota
+	XREF_IN_USE
+	XREF_COMPRESSED // Stored in an object stream (only in xref streams)
+)
+
+// XRefEntry is a single entry in a cross-reference table. For compressed
+// entries, Offset is the object number of the object stream containing the
+// object and Gen is the index of the object within the stream.
+type XRefEntry struct {
+	Id     int64 // The object number
+	Offset int64 // Byte offset of the object, or the next free object if free
+	Gen    int64 // The generation number
+	Type   XRefEntryType
+}
+
+// XRefMismatch is an in use cross-reference entry that doesn't point at the
+// object it refers to
+type XRefMismatch struct {
+	Entry  XRefEntry
+	Actual int64 // The actual offset of the object, or -1 if it wasn't found
+}
+
 type XRefsNode struct {
 	*pdfNode
+	entries []XRefEntry
 }
 
 func NewXRefsNode() *XRefsNode {
@@ -250,13 +455,81 @@ func NewXRefsNode() *XRefsNode {
 		&pdfNode{
 			nodeType: XREFS,
 		},
+		nil,
+	}
+}
+
+// AddEntry adds an entry to the cross-reference table
+func (n *XRefsNode) AddEntry(entry XRefEntry) {
+	n.entries = append(n.entries, entry)
+}
+
+// Entries returns all entries in the cross-reference table, in the order they
+// appear
+func (n *XRefsNode) Entries() []XRefEntry {
+	return n.entries
+}
+
+// Entry returns the entry for the given object number if there is one
+func (n *XRefsNode) Entry(id int64) (XRefEntry, bool) {
+	for i := len(n.entries) - 1; i >= 0; i-- {
+		if n.entries[i].Id == id {
+			return n.entries[i], true
+		}
+	}
+
+	return XRefEntry{}, false
+}
+
+// FreeEntries returns the entries for free objects, in the order they appear
+func (n *XRefsNode) FreeEntries() []XRefEntry {
+	free := []XRefEntry{}
+
+	for _, entry := range n.entries {
+		if entry.Type == XREF_FREE {
+			free = append(free, entry)
+		}
+	}
+
+	return free
+}
+
+// Mismatches compares the in use entries against the actual offsets of the
+// given objects (as parsed), returning the entries that don't match.
+func (n *XRefsNode) Mismatches(objects []*IndirectObjectNode) []XRefMismatch {
+	offsets := make(map[[2]int64]int64)
+
+	for _, obj := range objects {
+		offsets[[2]int64{obj.Id(), obj.Gen()}] = obj.Offset()
 	}
+
+	mismatches := []XRefMismatch{}
+
+	for _, entry := range n.entries {
+		if entry.Type != XREF_IN_USE {
+			continue
+		}
+
+		actual, ok := offsets[[2]int64{entry.Id, entry.Gen}]
+
+		if !ok {
+			mismatches = append(mismatches, XRefMismatch{entry, -1})
+			continue
+		}
+
+		if actual != entry.Offset {
+			mismatches = append(mismatches, XRefMismatch{entry, actual})
+		}
+	}
+
+	return mismatches
 }
 
 type IndirectObjectNode struct {
 	*pdfNode
-	id  int64
-	gen int64
+	id     int64
+	gen    int64
+	offset int64
 }
 
 func NewIndirectObjectNode(id int64, gen int64) *IndirectObjectNode {
@@ -266,6 +539,36 @@ func NewIndirectObjectNode(id int64, gen int64) *IndirectObjectNode {
 		},
 		id,
 		gen,
+		-1,
+	}
+}
+
+// Offset returns the byte offset the object was parsed from, or -1 if unknown
+func (n *IndirectObjectNode) Offset() int64 {
+	return n.offset
+}
+
+// SetOffset sets the byte offset the object was parsed from
+func (n *IndirectObjectNode) SetOffset(offset int64) {
+	n.offset = offset
+}
+
+// AddChild adds a child to the object. A stream added to the object belongs
+// to it, so that it can find its dictionary.
+func (n *IndirectObjectNode) AddChild(child PdfNode) {
+	n.adopt(child)
+	n.pdfNode.AddChild(child)
+}
+
+// ReplaceChild replaces a child of the object with another node
+func (n *IndirectObjectNode) ReplaceChild(index int, child PdfNode) {
+	n.adopt(child)
+	n.pdfNode.ReplaceChild(index, child)
+}
+
+func (n *IndirectObjectNode) adopt(child PdfNode) {
+	if stream, ok := child.(*StreamNode); ok {
+		stream.object = n
 	}
 }
 
@@ -301,9 +604,23 @@ func (n *ObjectRefNode) Gen() int64 {
 	return n.gen
 }
 
+// DictNode is a dictionary. Its children are its keys (name nodes) and values,
+// alternating, in the order they appear. The children are the only record of
+// the entries, so changing them with AddChild, RemoveChild or ReplaceChild is
+// reflected by the dictionary methods.
+//
+// If a key appears more than once the last value is used, but the key keeps
+// its first position. A key without a value (from malformed input) is given a
+// null value by the parser and when the dictionary is changed with Set, so
+// keys and values stay paired.
 type DictNode struct {
 	*pdfNode
-	entries map[string]PdfNode
+}
+
+// DictEntry is a key and value of a dictionary
+type DictEntry struct {
+	Key   string
+	Value PdfNode
 }
 
 func NewDictNode() *DictNode {
@@ -311,33 +628,158 @@ func NewDictNode() *DictNode {
 		&pdfNode{
 			nodeType: DICT,
 		},
-		make(map[string]PdfNode),
 	}
 }
 
-func (n *DictNode) AddChild(child PdfNode) {
-	if len(n.children)%2 == 0 {
-		// If the child is not a name, then it's possible that we're dealing
-		// with an object reference as the eventual value, and it'll be popped
-		// off our children (becase the parsing of objects and references are
-		// done retroactively after seeing the keyword [obj or R]). So just add
-		// it to our children and return.
-		if child.Type() != NAME {
-			n.children = append(n.children, child)
-			return
+// Get returns the value for the key, or nil if there isn't one
+func (n *DictNode) Get(key string) PdfNode {
+	var value PdfNode
+
+	n.pairs(func(i int, k string) {
+		if k == key {
+			value = n.children[i+1]
 		}
+	})
 
-		n.entries[child.Value().(string)] = nil
-		n.children = append(n.children, child)
+	return value
+}
+
+// Has returns true if the dictionary has an entry for the key
+func (n *DictNode) Has(key string) bool {
+	return n.index(key) >= 0
+}
+
+// Set sets the value for the key. An existing entry keeps its position and
+// any duplicates of it are removed, otherwise the entry is added at the end.
+func (n *DictNode) Set(key string, value PdfNode) {
+	n.pad()
+	i := n.index(key)
+
+	if i < 0 {
+		n.children = append(n.children, NewNameNode(key), value)
 		return
 	}
 
-	n.children = append(n.children, child)
-	n.entries[n.children[len(n.children)-2].Value().(string)] = child
+	n.children[i+1] = value
+	n.remove(key, i+2)
 }
 
-func (n *DictNode) Get(key string) PdfNode {
-	return n.entries[key]
+// Delete removes the entry (and any duplicates of it) for the key, returning
+// true if there was one
+func (n *DictNode) Delete(key string) bool {
+	i := n.index(key)
+
+	if i < 0 {
+		return false
+	}
+
+	n.remove(key, i)
+	return true
+}
+
+// Keys returns the keys of the dictionary in the order they appear
+func (n *DictNode) Keys() []string {
+	keys := []string{}
+	seen := map[string]bool{}
+
+	n.pairs(func(i int, key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	})
+
+	return keys
+}
+
+// Len returns the number of entries in the dictionary, the same as the number
+// of keys given by Keys
+func (n *DictNode) Len() int {
+	seen := make(map[string]bool, len(n.children)/2)
+
+	n.pairs(func(i int, key string) {
+		seen[key] = true
+	})
+
+	return len(seen)
+}
+
+// Entries returns the entries of the dictionary in the order they appear
+func (n *DictNode) Entries() []DictEntry {
+	entries := make([]DictEntry, 0, n.Len())
+	positions := map[string]int{}
+
+	n.pairs(func(i int, key string) {
+		if position, ok := positions[key]; ok {
+			entries[position].Value = n.children[i+1]
+			return
+		}
+
+		positions[key] = len(entries)
+		entries = append(entries, DictEntry{Key: key, Value: n.children[i+1]})
+	})
+
+	return entries
+}
+
+// Range calls fn for each entry of the dictionary in the order they appear,
+// stopping if fn returns false
+func (n *DictNode) Range(fn func(key string, value PdfNode) bool) {
+	for _, entry := range n.Entries() {
+		if !fn(entry.Key, entry.Value) {
+			return
+		}
+	}
+}
+
+// pairs calls fn with the index and key of each key/value pair. Pairs without
+// a name as the key (from malformed input), or without a value, are skipped.
+func (n *DictNode) pairs(fn func(i int, key string)) {
+	for i := 0; i+1 < len(n.children); i += 2 {
+		if key, ok := n.children[i].(*NameNode); ok {
+			fn(i, key.Value().(string))
+		}
+	}
+}
+
+// pad gives a trailing key without a value a null value
+func (n *DictNode) pad() {
+	if len(n.children)%2 != 0 {
+		n.children = append(n.children, NewNullNode())
+	}
+}
+
+// index returns the index of the first child that is the given key, or -1
+func (n *DictNode) index(key string) int {
+	index := -1
+
+	n.pairs(func(i int, k string) {
+		if k == key && index < 0 {
+			index = i
+		}
+	})
+
+	return index
+}
+
+// remove removes the pairs for the key from the given child index onwards
+func (n *DictNode) remove(key string, from int) {
+	children := append([]PdfNode{}, n.children[:from]...)
+
+	for i := from; i < len(n.children); i += 2 {
+		if i+1 == len(n.children) {
+			children = append(children, n.children[i])
+			break
+		}
+
+		if k, ok := n.children[i].(*NameNode); ok && k.Value() == key {
+			continue
+		}
+
+		children = append(children, n.children[i], n.children[i+1])
+	}
+
+	n.children = children
 }
 
 type TrailerNode struct {
@@ -0,0 +1,148 @@
+package ast_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+)
+
+func TestDictNode_Set(t *testing.T) {
+	dict := ast.NewDictNode()
+	dict.Set("Type", ast.NewNameNode("Page"))
+	dict.Set("Count", ast.NewIntegerNode(1))
+	dict.Set("Type", ast.NewNameNode("Pages"))
+
+	expectKeys(t, dict, "Type", "Count")
+
+	if dict.Get("Type").Value() != "Pages" {
+		t.Errorf("Expected /Type /Pages, got %v", dict.Get("Type").Value())
+	}
+
+	if len(dict.Children()) != 4 {
+		t.Errorf("Expected 4 children, got %d", len(dict.Children()))
+	}
+}
+
+func TestDictNode_Delete(t *testing.T) {
+	dict := parse(t, "<< /A 1 /B 2 0 R /C 3 >>").Children()[0].(*ast.DictNode)
+
+	if !dict.Delete("B") {
+		t.Errorf("Expected /B to be deleted")
+	}
+
+	if dict.Delete("B") {
+		t.Errorf("Expected /B to be gone")
+	}
+
+	expectKeys(t, dict, "A", "C")
+
+	if dict.Has("B") || dict.Get("B") != nil {
+		t.Errorf("Expected no /B, got %v", dict.Get("B"))
+	}
+
+	if dict.Get("C").Value() != int64(3) {
+		t.Errorf("Expected /C 3, got %v", dict.Get("C").Value())
+	}
+}
+
+func TestDictNode_DuplicateKeys(t *testing.T) {
+	dict := parse(t, "<< /A 1 /B 2 /A 3 >>").Children()[0].(*ast.DictNode)
+
+	// The last value wins, but the key keeps its first position
+	expectKeys(t, dict, "A", "B")
+
+	if len(dict.Entries()) != 2 {
+		t.Errorf("Expected 2 entries, got %v", dict.Entries())
+	}
+
+	if dict.Get("A").Value() != int64(3) {
+		t.Errorf("Expected /A 3, got %v", dict.Get("A").Value())
+	}
+
+	dict.Set("A", ast.NewIntegerNode(4))
+
+	if len(dict.Children()) != 4 || dict.Get("A").Value() != int64(4) {
+		t.Errorf("Expected the duplicate /A to be replaced, got %v", dict.Children())
+	}
+
+	dict = parse(t, "<< /A 1 /B 2 /A 3 >>").Children()[0].(*ast.DictNode)
+	dict.Delete("A")
+
+	expectKeys(t, dict, "B")
+}
+
+func TestDictNode_KeyWithoutValue(t *testing.T) {
+	dict := parse(t, "<< /A 1 /B >>").Children()[0].(*ast.DictNode)
+
+	expectKeys(t, dict, "A", "B")
+
+	if value := dict.Get("B"); value == nil || value.Type() != ast.NULL {
+		t.Errorf("Expected /B null, got %v", value)
+	}
+
+	// Children added directly can leave a key without a value
+	dict = ast.NewDictNode()
+	dict.AddChild(ast.NewNameNode("A"))
+	dict.AddChild(ast.NewIntegerNode(1))
+	dict.AddChild(ast.NewNameNode("B"))
+	dict.Set("C", ast.NewIntegerNode(3))
+
+	expectKeys(t, dict, "A", "B", "C")
+
+	if dict.Get("C").Value() != int64(3) || dict.Get("B").Type() != ast.NULL {
+		t.Errorf("Expected /B null and /C 3, got %v", dict.Children())
+	}
+}
+
+func TestDictNode_ChildrenAndEntriesAgree(t *testing.T) {
+	dict := parse(t, "<< /A 1 /B 2 >>").Children()[0].(*ast.DictNode)
+
+	dict.ReplaceChild(3, ast.NewIntegerNode(5))
+
+	if dict.Get("B").Value() != int64(5) {
+		t.Errorf("Expected /B 5 after ReplaceChild, got %v", dict.Get("B").Value())
+	}
+
+	dict.RemoveChild(1)
+	dict.RemoveChild(0)
+
+	expectKeys(t, dict, "B")
+
+	if dict.Has("A") {
+		t.Errorf("Expected /A to be removed with RemoveChild")
+	}
+}
+
+func TestDictNode_Range(t *testing.T) {
+	dict := parse(t, "<< /A 1 /B 2 /C 3 >>").Children()[0].(*ast.DictNode)
+	visited := []string{}
+
+	dict.Range(func(key string, value ast.PdfNode) bool {
+		visited = append(visited, key)
+		return key != "B"
+	})
+
+	if !reflect.DeepEqual(visited, []string{"A", "B"}) {
+		t.Errorf("Expected to stop after /B, got %v", visited)
+	}
+
+	entries := dict.Entries()
+
+	if len(entries) != 3 || entries[2].Key != "C" || entries[2].Value.Value() != int64(3) {
+		t.Errorf("Unexpected entries %v", entries)
+	}
+}
+
+// expectKeys checks the keys (and so the length) of a dictionary
+func expectKeys(t *testing.T, dict *ast.DictNode, keys ...string) {
+	t.Helper()
+
+	if !reflect.DeepEqual(dict.Keys(), keys) {
+		t.Errorf("Expected keys %v, got %v", keys, dict.Keys())
+	}
+
+	if dict.Len() != len(keys) {
+		t.Errorf("Expected length %d, got %d", len(keys), dict.Len())
+	}
+}
@@ -0,0 +1,567 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DiffKind is the kind of a difference between two trees
+type DiffKind int
+
+const (
+	DIFF_ADDED   DiffKind = iota // The node is only in the second tree
+	DIFF_REMOVED                 // The node is only in the first tree
+	DIFF_CHANGED                 // The node differs between the trees
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DIFF_ADDED:
+		return "ADDED"
+	case DIFF_REMOVED:
+		return "REMOVED"
+	case DIFF_CHANGED:
+		return "CHANGED"
+	}
+
+	return "UNKNOWN"
+}
+
+// Difference is a single difference between two trees. A is the node in the
+// first tree and B the node in the second, either of which is nil if the node
+// was added or removed.
+type Difference struct {
+	Kind DiffKind
+	Path Path
+	A    PdfNode
+	B    PdfNode
+}
+
+// String describes the difference in a line, in the style of a unified diff,
+// e.g. "~ [3][0]/Count: 1 -> 2"
+func (d Difference) String() string {
+	path := d.Path.String()
+
+	if path == "" {
+		path = "/"
+	}
+
+	switch d.Kind {
+	case DIFF_ADDED:
+		return fmt.Sprintf("+ %s: %s", path, describe(d.B))
+	case DIFF_REMOVED:
+		return fmt.Sprintf("- %s: %s", path, describe(d.A))
+	}
+
+	return fmt.Sprintf("~ %s: %s -> %s", path, describe(d.A), describe(d.B))
+}
+
+// EqualOption changes how Equal and Diff compare nodes
+type EqualOption func(*comparer)
+
+// IgnoreObjectNumbers compares indirect objects and references without their
+// object and generation numbers, so that a renumbered document is equal to the
+// original. Numbers must still correspond one to one: where the first tree
+// uses object 3, the second must consistently use the same number. Objects are
+// matched by following references from the trailer, and any left over are
+// matched in the order they appear.
+func IgnoreObjectNumbers() EqualOption {
+	return func(c *comparer) {
+		c.ignoreObjectNumbers = true
+	}
+}
+
+// IgnoreFloatPrecision treats numbers as equal if they differ by no more than
+// the tolerance, whether they are written as integers or reals
+func IgnoreFloatPrecision(tolerance float64) EqualOption {
+	return func(c *comparer) {
+		c.ignoreFloatPrecision = true
+		c.tolerance = tolerance
+	}
+}
+
+// IgnoreKeyOrder treats dictionaries with the same entries in a different
+// order as equal
+func IgnoreKeyOrder() EqualOption {
+	return func(c *comparer) {
+		c.ignoreKeyOrder = true
+	}
+}
+
+// Equal returns true if two trees are structurally equal. By default nodes
+// must have the same type, value and children in the same order, although
+// whether strings are written as hex or literals doesn't matter. The layout of
+// the file is ignored: the offsets objects were parsed from, cross-reference
+// tables, startxref and the /Prev and /XRefStm trailer entries.
+func Equal(a PdfNode, b PdfNode, options ...EqualOption) bool {
+	return len(Diff(a, b, options...)) == 0
+}
+
+// Diff returns the differences between two trees, in the order they appear.
+// Dictionaries are compared entry by entry, the indirect objects of documents
+// by object number and other children by position. If a node has changed its
+// children aren't compared.
+func Diff(a PdfNode, b PdfNode, options ...EqualOption) []Difference {
+	c := &comparer{
+		ids:     map[[2]int64][2]int64{},
+		reverse: map[[2]int64][2]int64{},
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	c.diff(a, b, Path{})
+	return c.differences
+}
+
+// comparer compares trees, collecting differences
+type comparer struct {
+	ignoreObjectNumbers  bool
+	ignoreFloatPrecision bool
+	ignoreKeyOrder       bool
+	tolerance            float64
+
+	// Object numbers in the first tree matched to those in the second, and the
+	// other way around, when ignoring object numbers
+	ids     map[[2]int64][2]int64
+	reverse map[[2]int64][2]int64
+	matched [][2]int64 // Keys of ids, in the order they were matched
+
+	differences []Difference
+}
+
+func (c *comparer) report(kind DiffKind, path Path, a PdfNode, b PdfNode) {
+	// Copy the path, as it's shared while walking
+	path = append(Path{}, path...)
+	c.differences = append(c.differences, Difference{kind, path, a, b})
+}
+
+func (c *comparer) diff(a PdfNode, b PdfNode, path Path) {
+	if a == nil || b == nil {
+		if a != nil {
+			c.report(DIFF_REMOVED, path, a, nil)
+		} else if b != nil {
+			c.report(DIFF_ADDED, path, nil, b)
+		}
+
+		return
+	}
+
+	if !c.sameValue(a, b) {
+		c.report(DIFF_CHANGED, path, a, b)
+		return
+	}
+
+	switch a.Type() {
+	case DICT:
+		c.diffDict(a.(*DictNode), b.(*DictNode), path)
+		return
+
+	case ROOT:
+		c.diffRoot(a, b, path)
+		return
+
+	case TRAILER:
+		// Only the dictionary describes the document, startxref is an offset
+		aChildren, aIndexes := trailerDicts(a)
+		bChildren, bIndexes := trailerDicts(b)
+		c.diffChildren(aChildren, bChildren, aIndexes, bIndexes, path)
+		return
+
+	case XREFS:
+		// Cross-reference tables have no children, and are compared as a whole
+		return
+	}
+
+	c.diffChildren(a.Children(), b.Children(), nil, nil, path)
+}
+
+// diffChildren compares children by position. The indexes of the children
+// within their parents are given for the path, or nil if they're the children
+// themselves.
+func (c *comparer) diffChildren(a []PdfNode, b []PdfNode, aIndexes []int, bIndexes []int, path Path) {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var aChild, bChild PdfNode
+		index := i
+
+		if i < len(b) {
+			bChild = b[i]
+
+			if bIndexes != nil {
+				index = bIndexes[i]
+			}
+		}
+
+		if i < len(a) {
+			aChild = a[i]
+
+			if aIndexes != nil {
+				index = aIndexes[i]
+			}
+		}
+
+		c.diff(aChild, bChild, append(path, PathStep{Index: index}))
+	}
+}
+
+// diffRoot compares two documents. Indirect objects are matched by object
+// number (or by following references when ignoring numbers), trailers and
+// anything else by position, and cross-reference tables are skipped. The
+// differences are given in the order the nodes appear in the documents.
+func (c *comparer) diffRoot(a PdfNode, b PdfNode, path Path) {
+	start := len(c.differences)
+	aObjects, aTrailers, aOthers := rootChildren(a)
+	bObjects, bTrailers, bOthers := rootChildren(b)
+
+	// Trailers are compared first, as their references match up objects
+	c.diffChildren(aTrailers.nodes, bTrailers.nodes, aTrailers.indexes, bTrailers.indexes, path)
+	c.diffChildren(aOthers.nodes, bOthers.nodes, aOthers.indexes, bOthers.indexes, path)
+	c.diffObjects(aObjects, bObjects, path)
+
+	differences := c.differences[start:]
+
+	sort.SliceStable(differences, func(i, j int) bool {
+		return differences[i].Path[len(path)].Index < differences[j].Path[len(path)].Index
+	})
+}
+
+// diffObjects compares the indirect objects of two documents, matching them
+// by object number. Objects with the same number in more than one revision are
+// matched in the order they appear.
+func (c *comparer) diffObjects(a indexedNodes, b indexedNodes, path Path) {
+	aIds := a.byObjectNumber()
+	bIds := b.byObjectNumber()
+	aDone := map[int]bool{}
+	bDone := map[int]bool{}
+
+	// match compares the objects of a with one number to those of b with
+	// another, in the order they appear
+	match := func(aId [2]int64, bId [2]int64) {
+		for _, i := range aIds[aId] {
+			if aDone[i] {
+				continue
+			}
+
+			for _, j := range bIds[bId] {
+				if !bDone[j] {
+					aDone[i] = true
+					bDone[j] = true
+					c.diff(a.nodes[i], b.nodes[j], append(path, PathStep{Index: a.indexes[i]}))
+					break
+				}
+			}
+		}
+	}
+
+	if !c.ignoreObjectNumbers {
+		for _, node := range a.nodes {
+			id := objectNumber(node)
+			match(id, id)
+		}
+	} else {
+		// Comparing objects matches up the objects they reference, which are
+		// compared in turn. Objects that nothing references are matched in
+		// the order they appear.
+		for k, i, j := 0, 0, 0; ; {
+			for ; k < len(c.matched); k++ {
+				id := c.matched[k]
+				match(id, c.ids[id])
+			}
+
+			// Objects only ever become matched, so the search carries on
+			// from the last one found
+			i = unmatchedObject(a, i, aDone, c.ids)
+			j = unmatchedObject(b, j, bDone, c.reverse)
+
+			if i == -1 || j == -1 {
+				break
+			}
+
+			aDone[i] = true
+			bDone[j] = true
+			c.diff(a.nodes[i], b.nodes[j], append(path, PathStep{Index: a.indexes[i]}))
+		}
+	}
+
+	for i, node := range a.nodes {
+		if !aDone[i] {
+			c.report(DIFF_REMOVED, append(path, PathStep{Index: a.indexes[i]}), node, nil)
+		}
+	}
+
+	for j, node := range b.nodes {
+		if !bDone[j] {
+			c.report(DIFF_ADDED, append(path, PathStep{Index: b.indexes[j]}), nil, node)
+		}
+	}
+}
+
+// unmatchedObject returns the position of the first object from the given one
+// that hasn't been compared or matched to an object number in the other tree,
+// or -1 if there isn't one
+func unmatchedObject(objects indexedNodes, from int, done map[int]bool, ids map[[2]int64][2]int64) int {
+	for i := from; i < len(objects.nodes); i++ {
+		if _, ok := ids[objectNumber(objects.nodes[i])]; !ok && !done[i] {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// indexedNodes are some of the children of a node, along with their indexes
+type indexedNodes struct {
+	nodes   []PdfNode
+	indexes []int
+}
+
+func (n *indexedNodes) add(node PdfNode, index int) {
+	n.nodes = append(n.nodes, node)
+	n.indexes = append(n.indexes, index)
+}
+
+// byObjectNumber returns the positions of indirect objects by their object
+// and generation numbers
+func (n *indexedNodes) byObjectNumber() map[[2]int64][]int {
+	ids := map[[2]int64][]int{}
+
+	for i, node := range n.nodes {
+		id := objectNumber(node)
+		ids[id] = append(ids[id], i)
+	}
+
+	return ids
+}
+
+func objectNumber(node PdfNode) [2]int64 {
+	obj := node.(*IndirectObjectNode)
+	return [2]int64{obj.Id(), obj.Gen()}
+}
+
+// rootChildren splits the children of a document into indirect objects,
+// trailers and anything else, leaving out cross-reference tables
+func rootChildren(root PdfNode) (objects indexedNodes, trailers indexedNodes, others indexedNodes) {
+	for i, child := range root.Children() {
+		switch child.Type() {
+		case INDIRECT_OBJECT:
+			objects.add(child, i)
+		case TRAILER:
+			trailers.add(child, i)
+		case XREFS:
+		default:
+			others.add(child, i)
+		}
+	}
+
+	return objects, trailers, others
+}
+
+// trailerDicts returns the dictionaries of a trailer, without the entries that
+// give offsets, along with their indexes
+func trailerDicts(trailer PdfNode) ([]PdfNode, []int) {
+	dicts := []PdfNode{}
+	indexes := []int{}
+
+	for i, child := range trailer.Children() {
+		dict, ok := child.(*DictNode)
+
+		if !ok {
+			continue
+		}
+
+		withoutOffsets := NewDictNode()
+
+		dict.Range(func(key string, value PdfNode) bool {
+			if key != "Prev" && key != "XRefStm" {
+				withoutOffsets.Set(key, value)
+			}
+
+			return true
+		})
+
+		dicts = append(dicts, withoutOffsets)
+		indexes = append(indexes, i)
+	}
+
+	return dicts, indexes
+}
+
+// diffDict compares the entries of two dictionaries by key
+func (c *comparer) diffDict(a *DictNode, b *DictNode, path Path) {
+	if !c.ignoreKeyOrder && !sameKeyOrder(a, b) {
+		c.report(DIFF_CHANGED, path, a, b)
+		return
+	}
+
+	for _, key := range a.Keys() {
+		c.diff(a.Get(key), b.Get(key), append(path, PathStep{Key: key}))
+	}
+
+	for _, key := range b.Keys() {
+		if !a.Has(key) {
+			c.report(DIFF_ADDED, append(path, PathStep{Key: key}), nil, b.Get(key))
+		}
+	}
+}
+
+// sameValue compares the type and value of two nodes, but not their children
+func (c *comparer) sameValue(a PdfNode, b PdfNode) bool {
+	if c.ignoreFloatPrecision && isNumber(a) && isNumber(b) {
+		x, _ := Number(a)
+		y, _ := Number(b)
+		return math.Abs(x-y) <= c.tolerance
+	}
+
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *StringNode:
+		return bytes.Equal(a.Value().([]byte), b.Value().([]byte))
+	case *StreamNode:
+		return bytes.Equal(a.Value().([]byte), b.Value().([]byte))
+	case *IndirectObjectNode:
+		b := b.(*IndirectObjectNode)
+		return c.sameObject([2]int64{a.Id(), a.Gen()}, [2]int64{b.Id(), b.Gen()})
+	case *ObjectRefNode:
+		b := b.(*ObjectRefNode)
+		return c.sameObject([2]int64{a.Id(), a.Gen()}, [2]int64{b.Id(), b.Gen()})
+	case *XRefsNode:
+		return c.ignoreObjectNumbers || sameEntries(a.Entries(), b.(*XRefsNode).Entries())
+	}
+
+	return a.Value() == b.Value()
+}
+
+// sameObject returns true if two object numbers (and generations) refer to the
+// same object. When ignoring object numbers, the first time an object in one
+// tree is compared to an object in the other they are matched, so they must
+// always be compared to each other from then on.
+func (c *comparer) sameObject(a [2]int64, b [2]int64) bool {
+	if !c.ignoreObjectNumbers {
+		return a == b
+	}
+
+	matchA, okA := c.ids[a]
+	matchB, okB := c.reverse[b]
+
+	if !okA && !okB {
+		c.ids[a] = b
+		c.reverse[b] = a
+		c.matched = append(c.matched, a)
+		return true
+	}
+
+	return okA && okB && matchA == b && matchB == a
+}
+
+// sameKeyOrder returns true if the keys the dictionaries share are in the same
+// order in both
+func sameKeyOrder(a *DictNode, b *DictNode) bool {
+	shared := func(from *DictNode, other *DictNode) []string {
+		keys := []string{}
+
+		for _, key := range from.Keys() {
+			if other.Has(key) {
+				keys = append(keys, key)
+			}
+		}
+
+		return keys
+	}
+
+	aKeys := shared(a, b)
+	bKeys := shared(b, a)
+
+	for i := range aKeys {
+		if aKeys[i] != bKeys[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameEntries(a []XRefEntry, b []XRefEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isNumber(node PdfNode) bool {
+	return node.Type() == INTEGER || node.Type() == FLOAT
+}
+
+// describe returns a short description of a node, in PDF syntax where it's
+// short enough
+func describe(node PdfNode) string {
+	if node == nil {
+		return "nothing"
+	}
+
+	switch node := node.(type) {
+	case *BooleanNode:
+		return strconv.FormatBool(node.Value().(bool))
+	case *NullNode:
+		return "null"
+	case *IntegerNode:
+		return strconv.FormatInt(node.Value().(int64), 10)
+	case *FloatNode:
+		return strconv.FormatFloat(node.Value().(float64), 'f', -1, 64)
+	case *NameNode:
+		return "/" + node.Value().(string)
+	case *StringNode:
+		return describeString(node.Value().([]byte))
+	case *DictNode:
+		keys := []string{"<<"}
+
+		for _, key := range node.Keys() {
+			keys = append(keys, "/"+key)
+		}
+
+		return strings.Join(append(keys, ">>"), " ")
+	case *ArrayNode:
+		return fmt.Sprintf("array of %d", len(node.Children()))
+	case *StreamNode:
+		return fmt.Sprintf("stream of %d bytes", len(node.Value().([]byte)))
+	case *IndirectObjectNode:
+		return fmt.Sprintf("%d %d obj", node.Id(), node.Gen())
+	case *ObjectRefNode:
+		return fmt.Sprintf("%d %d R", node.Id(), node.Gen())
+	case *XRefsNode:
+		return fmt.Sprintf("xref of %d entries", len(node.Entries()))
+	case *TrailerNode:
+		return "trailer"
+	case *RootNode:
+		return "document"
+	}
+
+	return strings.ToLower(node.Type().String())
+}
+
+// describeString returns a string as a literal if it's printable, otherwise
+// in hex
+func describeString(value []byte) string {
+	for _, b := range value {
+		if b < 0x20 || b > 0x7e {
+			return "<" + hex.EncodeToString(value) + ">"
+		}
+	}
+
+	return "(" + string(value) + ")"
+}
@@ -0,0 +1,306 @@
+package ast_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+)
+
+const original = `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [] /Count 0 /MediaBox [0 0 612.0 792] >>
+endobj
+trailer
+<< /Size 3 /Root 1 0 R >>
+`
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		name     string
+		other    string
+		options  []ast.EqualOption
+		expected bool
+	}{
+		{
+			"identical",
+			original,
+			nil,
+			true,
+		},
+		{
+			"renumbered",
+			`%PDF-1.7
+5 0 obj
+<< /Type /Catalog /Pages 9 0 R >>
+endobj
+9 0 obj
+<< /Type /Pages /Kids [] /Count 0 /MediaBox [0 0 612.0 792] >>
+endobj
+trailer
+<< /Size 3 /Root 5 0 R >>
+`,
+			nil,
+			false,
+		},
+		{
+			"renumbered, ignoring numbers",
+			`%PDF-1.7
+5 0 obj
+<< /Type /Catalog /Pages 9 0 R >>
+endobj
+9 0 obj
+<< /Type /Pages /Kids [] /Count 0 /MediaBox [0 0 612.0 792] >>
+endobj
+trailer
+<< /Size 3 /Root 5 0 R >>
+`,
+			[]ast.EqualOption{ast.IgnoreObjectNumbers()},
+			true,
+		},
+		{
+			"inconsistently renumbered, ignoring numbers",
+			`%PDF-1.7
+5 0 obj
+<< /Type /Catalog /Pages 9 0 R >>
+endobj
+9 0 obj
+<< /Type /Pages /Kids [] /Count 0 /MediaBox [0 0 612.0 792] >>
+endobj
+trailer
+<< /Size 3 /Root 9 0 R >>
+`,
+			[]ast.EqualOption{ast.IgnoreObjectNumbers()},
+			false,
+		},
+		{
+			"imprecise",
+			`%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [] /Count 0 /MediaBox [0 0 612 792.0001] >>
+endobj
+trailer
+<< /Size 3 /Root 1 0 R >>
+`,
+			nil,
+			false,
+		},
+		{
+			"imprecise, ignoring precision",
+			`%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [] /Count 0 /MediaBox [0 0 612 792.0001] >>
+endobj
+trailer
+<< /Size 3 /Root 1 0 R >>
+`,
+			[]ast.EqualOption{ast.IgnoreFloatPrecision(0.001)},
+			true,
+		},
+		{
+			"reordered",
+			`%PDF-1.7
+1 0 obj
+<< /Pages 2 0 R /Type /Catalog >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [] /Count 0 /MediaBox [0 0 612.0 792] >>
+endobj
+trailer
+<< /Root 1 0 R /Size 3 >>
+`,
+			nil,
+			false,
+		},
+		{
+			"reordered, ignoring key order",
+			`%PDF-1.7
+1 0 obj
+<< /Pages 2 0 R /Type /Catalog >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [] /Count 0 /MediaBox [0 0 612.0 792] >>
+endobj
+trailer
+<< /Root 1 0 R /Size 3 >>
+`,
+			[]ast.EqualOption{ast.IgnoreKeyOrder()},
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		a := parse(t, original)
+		b := parse(t, test.other)
+
+		if equal := ast.Equal(a, b, test.options...); equal != test.expected {
+			t.Errorf("%s: expected Equal to return %v, got %v", test.name, test.expected, equal)
+		}
+	}
+}
+
+func TestEqual_HexStrings(t *testing.T) {
+	a := ast.NewStringNode([]byte("abc"))
+	b := ast.NewHexStringNode([]byte("abc"))
+
+	if !ast.Equal(a, b) {
+		t.Errorf("Expected hex and literal strings with the same bytes to be equal")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := parse(t, original)
+	b := parse(t, `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R /Lang (en) >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 612.0 792] >>
+endobj
+trailer
+<< /Size 4 /Root 1 0 R >>
+`)
+
+	differences := ast.Diff(a, b)
+	lines := []string{}
+
+	for _, difference := range differences {
+		lines = append(lines, difference.String())
+	}
+
+	expected := []string{
+		"+ [0][0]/Lang: (en)",
+		"+ [1][0]/Kids[0]: 3 0 R",
+		"~ [1][0]/Count: 0 -> 1",
+		"~ [2][0]/Size: 3 -> 4",
+	}
+
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected differences %q, got %q", expected, lines)
+	}
+
+	if differences[1].Kind != ast.DIFF_ADDED || differences[1].A != nil {
+		t.Errorf("Expected an added node, got %+v", differences[1])
+	}
+
+	if differences[2].Kind != ast.DIFF_CHANGED || differences[2].A.Value() != int64(0) {
+		t.Errorf("Expected a changed node, got %+v", differences[2])
+	}
+}
+
+func TestDiff_Removed(t *testing.T) {
+	a := parse(t, original)
+	b := parse(t, `%PDF-1.7
+1 0 obj
+<< /Type /Catalog >>
+endobj
+`)
+
+	differences := ast.Diff(a, b)
+
+	if len(differences) != 3 {
+		t.Fatalf("Expected 3 differences, got %v", differences)
+	}
+
+	expected := []string{
+		"- [0][0]/Pages: 2 0 R",
+		"- [1]: 2 0 obj",
+		"- [2]: trailer",
+	}
+
+	for i, difference := range differences {
+		if difference.Kind != ast.DIFF_REMOVED || difference.String() != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], difference)
+		}
+	}
+}
+
+func TestEqual_IgnoresLayout(t *testing.T) {
+	a := parse(t, `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [] /Count 0 >>
+endobj
+xref
+0 3
+0000000000 65535 f 
+0000000009 00000 n 
+0000000055 00000 n 
+trailer
+<< /Size 3 /Root 1 0 R >>
+startxref
+102
+%%EOF
+`)
+
+	// The same objects, two bytes further into the file
+	b := parse(t, `%PDF-1.7
+
+1 0 obj
+<<  /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [] /Count 0 >>
+endobj
+xref
+0 3
+0000000000 65535 f 
+0000000010 00000 n 
+0000000057 00000 n 
+trailer
+<< /Size 3 /Root 1 0 R >>
+startxref
+104
+%%EOF
+`)
+
+	for _, options := range [][]ast.EqualOption{nil, {ast.IgnoreObjectNumbers()}} {
+		if differences := ast.Diff(a, b, options...); len(differences) > 0 {
+			t.Errorf("Expected no differences, got %v", differences)
+		}
+	}
+}
+
+func TestEqual_MatchesObjectsByNumber(t *testing.T) {
+	reordered := parse(t, `%PDF-1.7
+2 0 obj
+<< /Type /Pages /Kids [] /Count 0 /MediaBox [0 0 612.0 792] >>
+endobj
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+trailer
+<< /Size 3 /Root 1 0 R >>
+`)
+
+	if differences := ast.Diff(parse(t, original), reordered); len(differences) > 0 {
+		t.Errorf("Expected no differences, got %v", differences)
+	}
+
+	// Renumbered as well as reordered
+	renumbered := parse(t, `%PDF-1.7
+9 0 obj
+<< /Type /Pages /Kids [] /Count 0 /MediaBox [0 0 612.0 792] >>
+endobj
+5 0 obj
+<< /Type /Catalog /Pages 9 0 R >>
+endobj
+trailer
+<< /Size 3 /Root 5 0 R >>
+`)
+
+	if differences := ast.Diff(parse(t, original), renumbered, ast.IgnoreObjectNumbers()); len(differences) > 0 {
+		t.Errorf("Expected no differences, got %v", differences)
+	}
+}
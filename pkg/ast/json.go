@@ -0,0 +1,304 @@
+package ast
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+var ErrInvalidJSON = errors.New("invalid JSON node")
+
+// jsonNode is the JSON representation of a node. Every node has its type and
+// children (for a dictionary, its keys and values in turn), plus whichever
+// fields hold the rest of its state. Strings and names are stored as text
+// when they are valid UTF-8, otherwise as base64 data, as stream data always
+// is.
+type jsonNode struct {
+	Type     string          `json:"type"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Data     []byte          `json:"data,omitempty"`
+	Hex      bool            `json:"hex,omitempty"`
+	Digits   string          `json:"digits,omitempty"`
+	Id       *int64          `json:"id,omitempty"`
+	Gen      *int64          `json:"gen,omitempty"`
+	Offset   *int64          `json:"offset,omitempty"`
+	XRefs    []jsonXRef      `json:"xrefs,omitempty"`
+	Children []*jsonNode     `json:"children,omitempty"`
+}
+
+type jsonXRef struct {
+	Id     int64  `json:"id"`
+	Offset int64  `json:"offset"`
+	Gen    int64  `json:"gen"`
+	Type   string `json:"type"`
+}
+
+var xrefTypeNames = map[XRefEntryType]string{
+	XREF_FREE:       "FREE",
+	XREF_IN_USE:     "IN_USE",
+	XREF_COMPRESSED: "COMPRESSED",
+}
+
+// MarshalJSON methods make every node type encode losslessly with
+// encoding/json. ImportJSON reverses the encoding.
+func (n *RootNode) MarshalJSON() ([]byte, error)           { return marshalJSON(n) }
+func (n *BooleanNode) MarshalJSON() ([]byte, error)        { return marshalJSON(n) }
+func (n *NullNode) MarshalJSON() ([]byte, error)           { return marshalJSON(n) }
+func (n *FloatNode) MarshalJSON() ([]byte, error)          { return marshalJSON(n) }
+func (n *IntegerNode) MarshalJSON() ([]byte, error)        { return marshalJSON(n) }
+func (n *NameNode) MarshalJSON() ([]byte, error)           { return marshalJSON(n) }
+func (n *StringNode) MarshalJSON() ([]byte, error)         { return marshalJSON(n) }
+func (n *DictNode) MarshalJSON() ([]byte, error)           { return marshalJSON(n) }
+func (n *ArrayNode) MarshalJSON() ([]byte, error)          { return marshalJSON(n) }
+func (n *FunctionNode) MarshalJSON() ([]byte, error)       { return marshalJSON(n) }
+func (n *StreamNode) MarshalJSON() ([]byte, error)         { return marshalJSON(n) }
+func (n *XRefsNode) MarshalJSON() ([]byte, error)          { return marshalJSON(n) }
+func (n *TrailerNode) MarshalJSON() ([]byte, error)        { return marshalJSON(n) }
+func (n *IndirectObjectNode) MarshalJSON() ([]byte, error) { return marshalJSON(n) }
+func (n *ObjectRefNode) MarshalJSON() ([]byte, error)      { return marshalJSON(n) }
+
+func marshalJSON(node PdfNode) ([]byte, error) {
+	j, err := toJSON(node)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(j)
+}
+
+// ImportJSON rebuilds a tree from its JSON encoding, as produced by
+// json.Marshal. A document comes back as a *RootNode.
+func ImportJSON(data []byte) (PdfNode, error) {
+	j := &jsonNode{}
+
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+	}
+
+	return fromJSON(j, Path{})
+}
+
+// toJSON converts a node and its children to their JSON representation
+func toJSON(node PdfNode) (*jsonNode, error) {
+	j := &jsonNode{Type: node.Type().String()}
+	var err error
+
+	switch node := node.(type) {
+	case *RootNode:
+		// The root holds the version from the header, e.g. PDF-1.7
+		if version, ok := node.Value().(string); ok {
+			j.Value, err = json.Marshal(version)
+		}
+	case *BooleanNode, *FloatNode, *IntegerNode:
+		j.Value, err = json.Marshal(node.Value())
+	case *NameNode:
+		err = j.setText([]byte(node.Value().(string)))
+	case *StringNode:
+		j.Hex = node.IsHex()
+		j.Digits = string(node.HexDigits())
+		err = j.setText(node.Value().([]byte))
+	case *StreamNode:
+		j.Data = node.Value().([]byte)
+	case *IndirectObjectNode:
+		id, gen := node.Id(), node.Gen()
+		j.Id, j.Gen = &id, &gen
+
+		if offset := node.Offset(); offset >= 0 {
+			j.Offset = &offset
+		}
+	case *ObjectRefNode:
+		id, gen := node.Id(), node.Gen()
+		j.Id, j.Gen = &id, &gen
+	case *XRefsNode:
+		for _, entry := range node.Entries() {
+			j.XRefs = append(j.XRefs, jsonXRef{entry.Id, entry.Offset, entry.Gen, xrefTypeNames[entry.Type]})
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, child := range node.Children() {
+		c, err := toJSON(child)
+
+		if err != nil {
+			return nil, err
+		}
+
+		j.Children = append(j.Children, c)
+	}
+
+	return j, nil
+}
+
+// setText stores text as the value if it's valid UTF-8, otherwise as data
+func (j *jsonNode) setText(text []byte) error {
+	if !utf8.Valid(text) {
+		j.Data = text
+		return nil
+	}
+
+	var err error
+	j.Value, err = json.Marshal(string(text))
+	return err
+}
+
+// text returns the text stored by setText
+func (j *jsonNode) text() ([]byte, error) {
+	if j.Value == nil {
+		return j.Data, nil
+	}
+
+	var text string
+	err := json.Unmarshal(j.Value, &text)
+	return []byte(text), err
+}
+
+// fromJSON rebuilds a node and its children from their JSON representation
+func fromJSON(j *jsonNode, path Path) (PdfNode, error) {
+	if j == nil {
+		return nil, fmt.Errorf("%w: %s: missing node", ErrInvalidJSON, location(path))
+	}
+
+	var node PdfNode
+	var err error
+
+	switch j.Type {
+	case ROOT.String():
+		node = NewRootNode()
+
+		if j.Value != nil {
+			var version string
+			err = json.Unmarshal(j.Value, &version)
+			node.SetValue(version)
+		}
+	case BOOLEAN.String():
+		var value bool
+		err = json.Unmarshal(j.Value, &value)
+		node = NewBooleanNode(value)
+	case NULL.String():
+		node = NewNullNode()
+	case FLOAT.String():
+		var value float64
+		err = json.Unmarshal(j.Value, &value)
+		node = NewFloatNode(value)
+	case INTEGER.String():
+		var value int64
+		err = json.Unmarshal(j.Value, &value)
+		node = NewIntegerNode(value)
+	case NAME.String():
+		var value []byte
+		value, err = j.text()
+		node = NewNameNode(string(value))
+	case STRING.String():
+		var value []byte
+		value, err = j.text()
+
+		if value == nil {
+			value = []byte{}
+		}
+
+		if j.Hex {
+			str := NewHexStringNode(value)
+
+			if j.Digits != "" {
+				str.SetHexDigits([]byte(j.Digits))
+			}
+
+			node = str
+		} else {
+			node = NewStringNode(value)
+		}
+	case DICT.String():
+		node = NewDictNode()
+	case ARRAY.String():
+		node = NewArrayNode()
+	case FUNCTION.String():
+		node = NewFunctionNode()
+	case STREAM.String():
+		value := j.Data
+
+		if value == nil {
+			value = []byte{}
+		}
+
+		node = NewStreamNode(value)
+	case XREFS.String():
+		xrefs := NewXRefsNode()
+		node = xrefs
+
+		for _, entry := range j.XRefs {
+			entryType, ok := xrefType(entry.Type)
+
+			if !ok {
+				return nil, fmt.Errorf("%w: %s: unknown cross-reference entry type %q", ErrInvalidJSON, location(path), entry.Type)
+			}
+
+			xrefs.AddEntry(XRefEntry{entry.Id, entry.Offset, entry.Gen, entryType})
+		}
+	case TRAILER.String():
+		node = NewTrailerNode()
+	case INDIRECT_OBJECT.String():
+		if j.Id == nil || j.Gen == nil {
+			return nil, fmt.Errorf("%w: %s: object without an id and generation", ErrInvalidJSON, location(path))
+		}
+
+		obj := NewIndirectObjectNode(*j.Id, *j.Gen)
+
+		if j.Offset != nil {
+			obj.SetOffset(*j.Offset)
+		}
+
+		node = obj
+	case OBJECT_REF.String():
+		if j.Id == nil || j.Gen == nil {
+			return nil, fmt.Errorf("%w: %s: reference without an id and generation", ErrInvalidJSON, location(path))
+		}
+
+		node = NewObjectRefNode(*j.Id, *j.Gen)
+	default:
+		return nil, fmt.Errorf("%w: %s: unknown type %q", ErrInvalidJSON, location(path), j.Type)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidJSON, location(path), err)
+	}
+
+	for i, c := range j.Children {
+		child, err := fromJSON(c, append(path, PathStep{Index: i}))
+
+		if err != nil {
+			return nil, err
+		}
+
+		node.AddChild(child)
+	}
+
+	if node.Type() == DICT && len(node.Children())%2 != 0 {
+		return nil, fmt.Errorf("%w: %s: dictionary key without a value", ErrInvalidJSON, location(path))
+	}
+
+	return node, nil
+}
+
+func xrefType(name string) (XRefEntryType, bool) {
+	for entryType, n := range xrefTypeNames {
+		if n == name {
+			return entryType, true
+		}
+	}
+
+	return 0, false
+}
+
+// location describes where in the tree a path leads, for errors
+func location(path Path) string {
+	if len(path) == 0 {
+		return "/"
+	}
+
+	return path.String()
+}
@@ -0,0 +1,128 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/serialiser"
+)
+
+const jsonDocument = `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R /ID [<00ff10> (literal)] /Version 1.5 /Open true /Extra null >>
+endobj
+2 0 obj
+<< /Length 11 >>
+stream
+hello world
+endstream
+endobj
+xref
+0 3
+0000000000 65535 f
+0000000009 00000 n
+0000000112 00000 n
+trailer
+<< /Size 3 /Root 1 0 R >>
+`
+
+func TestImportJSON_RoundTrip(t *testing.T) {
+	root := parse(t, jsonDocument)
+
+	data, err := json.Marshal(root)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	imported, err := ast.ImportJSON(data)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, ok := imported.(*ast.RootNode); !ok {
+		t.Fatalf("Expected a root node, got %T", imported)
+	}
+
+	if differences := ast.Diff(root, imported); len(differences) > 0 {
+		t.Errorf("Expected the imported tree to be equal, got %v", differences)
+	}
+
+	s := serialiser.NewSerialiser()
+	expected, _ := s.Serialise(root)
+	actual, err := s.Serialise(imported)
+
+	if err != nil || actual != expected {
+		t.Errorf("Expected the imported tree to serialise to\n%s\ngot\n%s (%v)", expected, actual, err)
+	}
+
+	// Encoding again gives the same JSON
+	again, _ := json.Marshal(imported)
+
+	if string(again) != string(data) {
+		t.Errorf("Expected the same JSON, got\n%s\nand\n%s", data, again)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	for _, test := range []struct {
+		node     ast.PdfNode
+		expected string
+	}{
+		{ast.NewIntegerNode(42), `{"type":"INTEGER","value":42}`},
+		{ast.NewBooleanNode(false), `{"type":"BOOLEAN","value":false}`},
+		{ast.NewNameNode("Type"), `{"type":"NAME","value":"Type"}`},
+		{ast.NewHexStringNode([]byte("hi")), `{"type":"STRING","value":"hi","hex":true}`},
+		{ast.NewStringNode([]byte{0xff, 0xfe}), `{"type":"STRING","data":"//4="}`},
+		{ast.NewStreamNode([]byte("abc")), `{"type":"STREAM","data":"YWJj"}`},
+		{ast.NewObjectRefNode(3, 0), `{"type":"OBJECT_REF","id":3,"gen":0}`},
+	} {
+		data, err := json.Marshal(test.node)
+
+		if err != nil || string(data) != test.expected {
+			t.Errorf("Expected %s, got %s (%v)", test.expected, data, err)
+		}
+	}
+}
+
+func TestImportJSON_KeepsHexDigits(t *testing.T) {
+	str := ast.NewHexStringNode([]byte("hi"))
+	str.SetHexDigits([]byte("6869"))
+
+	data, err := json.Marshal(str)
+
+	if err != nil || string(data) != `{"type":"STRING","value":"hi","hex":true,"digits":"6869"}` {
+		t.Fatalf("Unexpected JSON %s (%v)", data, err)
+	}
+
+	imported, err := ast.ImportJSON(data)
+
+	if err != nil || string(imported.(*ast.StringNode).HexDigits()) != "6869" {
+		t.Errorf("Expected the digits to be kept, got %v (%v)", imported, err)
+	}
+}
+
+func TestImportJSON_Invalid(t *testing.T) {
+	for _, input := range []string{
+		`not json`,
+		`{"type":"PAGE"}`,
+		`{"type":"INTEGER","value":"one"}`,
+		`{"type":"ARRAY","children":[{"type":"OBJECT_REF","id":1}]}`,
+		`{"type":"XREFS","xrefs":[{"id":0,"offset":0,"gen":0,"type":"USED"}]}`,
+		`{"type":"DICT","children":[{"type":"NAME","value":"A"}]}`,
+	} {
+		if _, err := ast.ImportJSON([]byte(input)); !errors.Is(err, ast.ErrInvalidJSON) {
+			t.Errorf("%s: expected ErrInvalidJSON, got %v", input, err)
+		}
+	}
+
+	_, err := ast.ImportJSON([]byte(`{"type":"ARRAY","children":[{"type":"NULL"},{"type":"OBJECT_REF"}]}`))
+
+	if err == nil || !strings.Contains(err.Error(), "[1]") {
+		t.Errorf("Expected the error to give the path, got %v", err)
+	}
+}
@@ -0,0 +1,359 @@
+package ast
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rgracey/pdf/pkg/token"
+	"github.com/rgracey/pdf/pkg/tokeniser"
+)
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Query finds nodes in a tree, similar to XPath. A query is a series of steps,
+// each selecting nodes by type from the children (/) or descendants (//) of
+// the nodes selected by the previous step. A type of * matches any node.
+// Predicates in brackets narrow down the dictionaries (or the dictionaries of
+// indirect objects) matched, either requiring a key ([/Key]) or a value for it
+// ([/Key=/Name], [/Key=3], [/Key=(string)], [/Key=true], [/Key=null]).
+// Strings are written as in a document, so [/T=(a\)b)] matches the string a)b.
+//
+// For example //DICT[/Type=/Page] finds every page dictionary, and
+// /INDIRECT_OBJECT[/Type=/Font]/STREAM finds the streams of font objects.
+//
+// As with Walk, the children of a dictionary are its values rather than its
+// keys.
+type Query struct {
+	steps []queryStep
+}
+
+type queryStep struct {
+	descendants bool // Whether the step matches descendants or just children
+	nodeType    Type // The type to match, unless any is true
+	any         bool
+	predicates  []predicate
+}
+
+type predicate struct {
+	key   string
+	value PdfNode // The value to match, or nil if the key just has to exist
+}
+
+// Types by name, for parsing queries
+var typeNames = map[string]Type{}
+
+func init() {
+	for t := ROOT; t <= NULL; t++ {
+		typeNames[t.String()] = t
+	}
+}
+
+// CompileQuery parses a query
+func CompileQuery(query string) (*Query, error) {
+	q := &Query{}
+	rest := query
+
+	if rest == "" {
+		return nil, fmt.Errorf("%w: empty query", ErrInvalidQuery)
+	}
+
+	for rest != "" {
+		if !strings.HasPrefix(rest, "/") {
+			return nil, fmt.Errorf("%w: expected / at %q", ErrInvalidQuery, rest)
+		}
+
+		step := queryStep{}
+		rest = rest[1:]
+
+		if strings.HasPrefix(rest, "/") {
+			step.descendants = true
+			rest = rest[1:]
+		}
+
+		end := strings.IndexAny(rest, "/[")
+
+		if end < 0 {
+			end = len(rest)
+		}
+
+		name := rest[:end]
+		rest = rest[end:]
+
+		if name == "*" {
+			step.any = true
+		} else if nodeType, ok := typeNames[name]; ok {
+			step.nodeType = nodeType
+		} else {
+			return nil, fmt.Errorf("%w: unknown node type %q", ErrInvalidQuery, name)
+		}
+
+		for strings.HasPrefix(rest, "[") {
+			end := predicateEnd(rest)
+
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated predicate %q", ErrInvalidQuery, rest)
+			}
+
+			pred, err := parsePredicate(rest[1:end])
+
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+			}
+
+			step.predicates = append(step.predicates, pred)
+			rest = rest[end+1:]
+		}
+
+		q.steps = append(q.steps, step)
+	}
+
+	return q, nil
+}
+
+// Find compiles a query and finds the nodes it matches, starting from node.
+// Object references are followed through the resolver, if one is given.
+func Find(node PdfNode, query string, r *Resolver) ([]PdfNode, error) {
+	q, err := CompileQuery(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return q.Find(node, r), nil
+}
+
+// Find returns the nodes matched by the query, in the order they're found,
+// starting from node. If a resolver is given, object references are followed,
+// so that the object a reference points to is treated as the child of the
+// reference. Each node is only visited once, so cycles of references are safe.
+func (q *Query) Find(node PdfNode, r *Resolver) []PdfNode {
+	current := []PdfNode{node}
+
+	for _, step := range q.steps {
+		next := []PdfNode{}
+		seen := map[PdfNode]bool{}
+
+		for _, n := range current {
+			candidates := queryChildren(n, r)
+
+			if step.descendants {
+				candidates = queryDescendants(n, r)
+			}
+
+			for _, candidate := range candidates {
+				if !seen[candidate] && step.matches(candidate, r) {
+					seen[candidate] = true
+					next = append(next, candidate)
+				}
+			}
+		}
+
+		current = next
+	}
+
+	return current
+}
+
+// matches returns true if a node matches the type and predicates of the step
+func (s queryStep) matches(node PdfNode, r *Resolver) bool {
+	if !s.any && node.Type() != s.nodeType {
+		return false
+	}
+
+	if len(s.predicates) == 0 {
+		return true
+	}
+
+	dict, ok := node.(*DictNode)
+
+	if obj, isObj := node.(*IndirectObjectNode); isObj && len(obj.Children()) > 0 {
+		dict, ok = obj.Children()[0].(*DictNode)
+	}
+
+	if !ok {
+		return false
+	}
+
+	for _, pred := range s.predicates {
+		value := dict.Get(pred.key)
+
+		if r != nil {
+			if resolved, err := r.ResolveDeep(value); err == nil {
+				value = resolved
+			}
+		}
+
+		if value == nil || (pred.value != nil && !literalEqual(pred.value, value)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// queryChildren returns the children of a node for a query. The object a
+// reference points to is its child if there is a resolver.
+func queryChildren(node PdfNode, r *Resolver) []PdfNode {
+	if ref, ok := node.(*ObjectRefNode); ok {
+		if r == nil {
+			return nil
+		}
+
+		obj, err := r.Object(ref)
+
+		if err != nil {
+			return nil
+		}
+
+		return []PdfNode{obj}
+	}
+
+	children := []PdfNode{}
+
+	for _, child := range childSteps(node) {
+		if child.node != nil {
+			children = append(children, child.node)
+		}
+	}
+
+	return children
+}
+
+// queryDescendants returns the descendants of a node for a query, depth first
+func queryDescendants(node PdfNode, r *Resolver) []PdfNode {
+	descendants := []PdfNode{}
+	visited := map[PdfNode]bool{node: true}
+
+	var visit func(node PdfNode)
+	visit = func(node PdfNode) {
+		for _, child := range queryChildren(node, r) {
+			if visited[child] {
+				continue
+			}
+
+			visited[child] = true
+			descendants = append(descendants, child)
+			visit(child)
+		}
+	}
+
+	visit(node)
+	return descendants
+}
+
+// predicateEnd returns the index of the bracket closing the predicate at the
+// start of the query, ignoring any inside a string, or -1 if there isn't one
+func predicateEnd(query string) int {
+	depth := 0
+
+	for i := 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ']':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// parsePredicate parses the inside of a predicate, e.g. /Type=/Page
+func parsePredicate(text string) (predicate, error) {
+	if !strings.HasPrefix(text, "/") {
+		return predicate{}, fmt.Errorf("predicate %q should start with a key", text)
+	}
+
+	key, value, hasValue := strings.Cut(text[1:], "=")
+	key = strings.TrimSpace(key)
+
+	if key == "" {
+		return predicate{}, fmt.Errorf("predicate %q has an empty key", text)
+	}
+
+	if !hasValue {
+		return predicate{key: key}, nil
+	}
+
+	literal, err := parseLiteral(strings.TrimSpace(value))
+
+	if err != nil {
+		return predicate{}, err
+	}
+
+	return predicate{key: key, value: literal}, nil
+}
+
+// parseLiteral parses the value of a predicate
+func parseLiteral(text string) (PdfNode, error) {
+	switch {
+	case strings.HasPrefix(text, "/") && len(text) > 1:
+		return NewNameNode(text[1:]), nil
+	case strings.HasPrefix(text, "("):
+		return parseString(text)
+	case text == "true" || text == "false":
+		return NewBooleanNode(text == "true"), nil
+	case text == "null":
+		return NewNullNode(), nil
+	}
+
+	if integer, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return NewIntegerNode(integer), nil
+	}
+
+	if float, err := strconv.ParseFloat(text, 64); err == nil {
+		return NewFloatNode(float), nil
+	}
+
+	return nil, fmt.Errorf("invalid value %q", text)
+}
+
+// parseString parses a string literal the way the tokeniser reads one from a
+// document, decoding escapes such as \) and \n
+func parseString(text string) (PdfNode, error) {
+	t := tokeniser.NewTokeniser(strings.NewReader(text))
+	tok, err := t.NextToken()
+
+	if err != nil || tok.Type != token.STRING_LITERAL {
+		return nil, fmt.Errorf("invalid string %q", text)
+	}
+
+	// Nothing can follow the string
+	if next, err := t.NextToken(); err != nil || next.Type != token.EOF {
+		return nil, fmt.Errorf("invalid string %q", text)
+	}
+
+	return NewStringNode(tok.Value.([]byte)), nil
+}
+
+// literalEqual returns true if a node has the value of a predicate literal.
+// Integers and reals are compared by value.
+func literalEqual(literal PdfNode, node PdfNode) bool {
+	if literal.Type() == INTEGER || literal.Type() == FLOAT {
+		a, errA := Number(literal)
+		b, errB := Number(node)
+		return errA == nil && errB == nil && a == b
+	}
+
+	if literal.Type() != node.Type() {
+		return false
+	}
+
+	switch literal.Type() {
+	case STRING:
+		return bytes.Equal(literal.Value().([]byte), node.Value().([]byte))
+	case NULL:
+		return true
+	}
+
+	return literal.Value() == node.Value()
+}
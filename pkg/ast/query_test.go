@@ -0,0 +1,147 @@
+package ast_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+)
+
+func TestFind(t *testing.T) {
+	root := parse(t, document)
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{"//DICT[/Type=/Page]", 1},
+		{"//DICT[/Type]", 4},
+		{"//DICT[/Count=1]", 1},
+		{"//DICT[/Count=1.0]", 1},
+		{"//DICT[/Count=2]", 0},
+		{"/INDIRECT_OBJECT[/Type=/Pages]", 1},
+		{"/INDIRECT_OBJECT/STRING", 1},
+		{"/INDIRECT_OBJECT/STRING/*", 0},
+		{"//STRING", 1},
+		{"//OBJECT_REF", 8},
+		{"/TRAILER/DICT[/Size=9][/Root]", 1},
+		{"/TRAILER/DICT[/Size=9][/Info]", 0},
+	}
+
+	for _, test := range tests {
+		nodes, err := ast.Find(root, test.query, nil)
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.query, err)
+			continue
+		}
+
+		if len(nodes) != test.expected {
+			t.Errorf("%s: expected %d nodes, got %d", test.query, test.expected, len(nodes))
+		}
+	}
+}
+
+func TestFind_FollowsReferences(t *testing.T) {
+	root := parse(t, document)
+	r := ast.NewRootResolver(root)
+
+	catalog, err := ast.Find(root, "/TRAILER/DICT/OBJECT_REF/INDIRECT_OBJECT", r)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(catalog) != 1 || catalog[0].(*ast.IndirectObjectNode).Id() != 1 {
+		t.Fatalf("Expected the catalog, got %v", catalog)
+	}
+
+	// Without a resolver the page can only be found as an object of the root,
+	// with one it can be reached from the catalog
+	pages, err := ast.Find(catalog[0], "//DICT[/Type=/Page]", nil)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(pages) != 0 {
+		t.Errorf("Expected no pages without a resolver, got %d", len(pages))
+	}
+
+	pages, err = ast.Find(catalog[0], "//DICT[/Type=/Page]", r)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(pages) != 1 {
+		t.Errorf("Expected one page, got %d", len(pages))
+	}
+
+	// The font is behind two references, and objects 6 and 7 form a cycle
+	fonts, err := ast.Find(root, "//DICT[/Type=/Font]", r)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(fonts) != 1 {
+		t.Errorf("Expected one font, got %d", len(fonts))
+	}
+}
+
+func TestFind_PredicatesResolveValues(t *testing.T) {
+	root := parse(t, document)
+
+	nodes, err := ast.Find(root, "//DICT[/Font=/Anything]", nil)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(nodes) != 0 {
+		t.Errorf("Expected no matches, got %d", len(nodes))
+	}
+
+	query, err := ast.CompileQuery("//DICT[/Resources]")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if nodes := query.Find(root, ast.NewRootResolver(root)); len(nodes) != 1 {
+		t.Errorf("Expected one match, got %d", len(nodes))
+	}
+}
+
+func TestFind_StringEscapes(t *testing.T) {
+	root := parse(t, "<< /T (a\\)b) >>")
+
+	for _, query := range []string{
+		`//DICT[/T=(a\)b)]`,
+		`//DICT[/T=(a\051b)]`,
+	} {
+		nodes, err := ast.Find(root, query, nil)
+
+		if err != nil || len(nodes) != 1 {
+			t.Errorf("%s: expected one match, got %d (%v)", query, len(nodes), err)
+		}
+	}
+}
+
+func TestCompileQuery_Invalid(t *testing.T) {
+	for _, query := range []string{
+		"",
+		"DICT",
+		"//PAGE",
+		"//DICT[/Type=/Page",
+		"//DICT[Type]",
+		"//DICT[/Type=Page]",
+		"//DICT[/T=(a)b)]",
+		"//DICT[/T=(a) (b)]",
+	} {
+		if _, err := ast.CompileQuery(query); !errors.Is(err, ast.ErrInvalidQuery) {
+			t.Errorf("%q: expected ErrInvalidQuery, got %v", query, err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnresolvedReference = errors.New("unresolved reference")
+	ErrCircularReference   = errors.New("circular reference")
+	ErrInvalidPath         = errors.New("invalid path")
+)
+
+// ObjectSource finds indirect objects by object number, e.g. a lazily loaded
+// document
+type ObjectSource interface {
+	Object(id int64) (*IndirectObjectNode, error)
+}
+
+// Resolver resolves object references to the objects they point to
+type Resolver struct {
+	source ObjectSource
+}
+
+// NewResolver creates a resolver that finds objects using the given source
+func NewResolver(source ObjectSource) *Resolver {
+	return &Resolver{source: source}
+}
+
+// NewRootResolver creates a resolver that finds objects among the children of
+// a parsed document. Where an object has several revisions, the newest is used.
+func NewRootResolver(root *RootNode) *Resolver {
+	return NewResolver(rootSource{root})
+}
+
+// Object returns the indirect object a reference points to
+func (r *Resolver) Object(ref *ObjectRefNode) (*IndirectObjectNode, error) {
+	obj, err := r.source.Object(ref.Id())
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d %d R: %v", ErrUnresolvedReference, ref.Id(), ref.Gen(), err)
+	}
+
+	if obj == nil || obj.Gen() != ref.Gen() {
+		return nil, fmt.Errorf("%w: %d %d R", ErrUnresolvedReference, ref.Id(), ref.Gen())
+	}
+
+	return obj, nil
+}
+
+// Resolve returns the value of the object a reference points to. For a stream
+// object this is its dictionary. Nodes other than references are returned as
+// they are.
+func (r *Resolver) Resolve(node PdfNode) (PdfNode, error) {
+	ref, ok := node.(*ObjectRefNode)
+
+	if !ok {
+		return node, nil
+	}
+
+	obj, err := r.Object(ref)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(obj.Children()) == 0 {
+		return NewNullNode(), nil
+	}
+
+	return obj.Children()[0], nil
+}
+
+// ResolveDeep follows references until it reaches a value that isn't one
+func (r *Resolver) ResolveDeep(node PdfNode) (PdfNode, error) {
+	visited := map[int64]bool{}
+
+	for {
+		ref, ok := node.(*ObjectRefNode)
+
+		if !ok {
+			return node, nil
+		}
+
+		if visited[ref.Id()] {
+			return nil, fmt.Errorf("%w: %d %d R", ErrCircularReference, ref.Id(), ref.Gen())
+		}
+
+		visited[ref.Id()] = true
+
+		var err error
+		node, err = r.Resolve(ref)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Path follows a path of dictionary keys and array indexes from a node,
+// resolving references along the way, e.g. Root/Pages/Kids[0]/Resources/Font
+// from the trailer
+func (r *Resolver) Path(node PdfNode, path string) (PdfNode, error) {
+	current, err := r.ResolveDeep(node)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Paths from the trailer start at its dictionary
+	if trailer, ok := current.(*TrailerNode); ok {
+		for _, child := range trailer.Children() {
+			if dict, ok := child.(*DictNode); ok {
+				current = dict
+			}
+		}
+	}
+
+	walked := ""
+
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		key, indexes, err := parseSegment(segment)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrInvalidPath, path, err)
+		}
+
+		dict, ok := current.(*DictNode)
+
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not a dictionary", ErrInvalidPath, walked)
+		}
+
+		walked += "/" + key
+
+		if current, err = r.ResolveDeep(dict.Get(key)); err != nil {
+			return nil, err
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("%w: %q not found", ErrInvalidPath, walked)
+		}
+
+		for _, index := range indexes {
+			array, ok := current.(*ArrayNode)
+
+			if !ok {
+				return nil, fmt.Errorf("%w: %q is not an array", ErrInvalidPath, walked)
+			}
+
+			walked += fmt.Sprintf("[%d]", index)
+
+			if index >= len(array.Children()) {
+				return nil, fmt.Errorf("%w: %q is out of range", ErrInvalidPath, walked)
+			}
+
+			if current, err = r.ResolveDeep(array.Children()[index]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return current, nil
+}
+
+// parseSegment splits a path segment like Kids[0] into its key and indexes
+func parseSegment(segment string) (string, []int, error) {
+	key := segment
+	indexes := []int{}
+
+	if start := strings.Index(segment, "["); start >= 0 {
+		key = segment[:start]
+
+		for _, part := range strings.Split(segment[start:], "]") {
+			if part == "" {
+				continue
+			}
+
+			if !strings.HasPrefix(part, "[") {
+				return "", nil, fmt.Errorf("malformed segment %q", segment)
+			}
+
+			index, err := strconv.Atoi(part[1:])
+
+			if err != nil || index < 0 {
+				return "", nil, fmt.Errorf("malformed index in %q", segment)
+			}
+
+			indexes = append(indexes, index)
+		}
+
+		if !strings.HasSuffix(segment, "]") {
+			return "", nil, fmt.Errorf("malformed segment %q", segment)
+		}
+	}
+
+	if key == "" {
+		return "", nil, fmt.Errorf("empty key in %q", segment)
+	}
+
+	return key, indexes, nil
+}
+
+// rootSource finds objects among the children of a parsed document
+type rootSource struct {
+	root *RootNode
+}
+
+func (s rootSource) Object(id int64) (*IndirectObjectNode, error) {
+	obj := s.root.Object(id)
+
+	if obj == nil {
+		return nil, fmt.Errorf("object %d not found", id)
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,143 @@
+package ast_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/parser"
+	"github.com/rgracey/pdf/pkg/tokeniser"
+)
+
+const document = `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R] /Count 1 >>
+endobj
+3 0 obj
+<< /Type /Page /Parent 2 0 R /Resources << /Font 4 0 R >> >>
+endobj
+4 0 obj
+5 0 R
+endobj
+5 0 obj
+<< /F1 << /Type /Font >> >>
+endobj
+6 0 obj
+7 0 R
+endobj
+7 0 obj
+6 0 R
+endobj
+8 1 obj
+(eight)
+endobj
+trailer
+<< /Size 9 /Root 1 0 R >>
+`
+
+func TestResolver_Resolve(t *testing.T) {
+	r := ast.NewRootResolver(parse(t, document))
+
+	node, err := r.Resolve(ast.NewObjectRefNode(2, 0))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if node.(*ast.DictNode).Get("Type").Value() != "Pages" {
+		t.Errorf("Expected the pages dictionary, got %v", node)
+	}
+
+	// Only a single reference is followed
+	node, err = r.Resolve(ast.NewObjectRefNode(4, 0))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if ref, ok := node.(*ast.ObjectRefNode); !ok || ref.Id() != 5 {
+		t.Errorf("Expected 5 0 R, got %v", node)
+	}
+
+	name := ast.NewNameNode("NotARef")
+
+	if node, _ := r.Resolve(name); node != name {
+		t.Errorf("Expected non-references to be returned as they are, got %v", node)
+	}
+}
+
+func TestResolver_ResolveReturnsErrorForMissingObjects(t *testing.T) {
+	r := ast.NewRootResolver(parse(t, document))
+
+	for _, ref := range []*ast.ObjectRefNode{
+		ast.NewObjectRefNode(10, 0),
+		ast.NewObjectRefNode(8, 0), // Wrong generation
+	} {
+		if _, err := r.Resolve(ref); !errors.Is(err, ast.ErrUnresolvedReference) {
+			t.Errorf("Expected ErrUnresolvedReference for %d %d R, got %v", ref.Id(), ref.Gen(), err)
+		}
+	}
+}
+
+func TestResolver_ResolveDeep(t *testing.T) {
+	r := ast.NewRootResolver(parse(t, document))
+
+	node, err := r.ResolveDeep(ast.NewObjectRefNode(4, 0))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if node.(*ast.DictNode).Get("F1") == nil {
+		t.Errorf("Expected the font dictionary, got %v", node)
+	}
+
+	if _, err := r.ResolveDeep(ast.NewObjectRefNode(6, 0)); !errors.Is(err, ast.ErrCircularReference) {
+		t.Errorf("Expected ErrCircularReference, got %v", err)
+	}
+}
+
+func TestResolver_Path(t *testing.T) {
+	root := parse(t, document)
+	r := ast.NewRootResolver(root)
+
+	node, err := r.Path(root.GetTrailer(), "Root/Pages/Kids[0]/Resources/Font/F1/Type")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if node.Value() != "Font" {
+		t.Errorf("Expected /Font, got %v", node.Value())
+	}
+
+	for _, path := range []string{
+		"Root/Missing",
+		"Root/Pages/Kids[1]",
+		"Root/Pages/Count[0]",
+		"Root/Type/Nested",
+		"Root/Pages/Kids[x]",
+		"Root//Pages",
+	} {
+		if _, err := r.Path(root.GetTrailer(), path); !errors.Is(err, ast.ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath for %s, got %v", path, err)
+		}
+	}
+}
+
+// parse parses a whole document, failing the test on error
+func parse(t *testing.T, input string) *ast.RootNode {
+	t.Helper()
+
+	root, err := parser.NewParser(tokeniser.NewTokeniser(strings.NewReader(input))).Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return root.(*ast.RootNode)
+}
@@ -0,0 +1,203 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rgracey/pdf/pkg/filters"
+)
+
+var (
+	ErrNoStreamDict   = errors.New("stream has no dictionary")
+	ErrInvalidFilters = errors.New("invalid stream filters")
+)
+
+// Object returns the indirect object the stream belongs to, or nil if it
+// hasn't been added to one
+func (n *StreamNode) Object() *IndirectObjectNode {
+	return n.object
+}
+
+// Dict returns the stream dictionary, from the indirect object the stream
+// belongs to, or nil if there isn't one
+func (n *StreamNode) Dict() *DictNode {
+	if n.object == nil {
+		return nil
+	}
+
+	for _, child := range n.object.Children() {
+		if dict, ok := child.(*DictNode); ok {
+			return dict
+		}
+	}
+
+	return nil
+}
+
+// Decoded returns the data of the stream with the filters given by its
+// dictionary applied in turn. Filters that aren't supported give an error
+// wrapping filters.ErrUnsupportedFilter.
+func (n *StreamNode) Decoded() ([]byte, error) {
+	dict := n.Dict()
+
+	if dict == nil {
+		return nil, ErrNoStreamDict
+	}
+
+	return DecodeStream(dict, n.Value().([]byte))
+}
+
+// SetDecoded encodes data with the given filters and stores it as the data of
+// the stream, updating /Filter, /DecodeParms and /Length in its dictionary to
+// match. Filters are named in the order they appear in /Filter, which is the
+// order they're applied to decode the data, and use their default parameters.
+// With no filters the data is stored as it is.
+func (n *StreamNode) SetDecoded(data []byte, filterNames ...string) error {
+	dict := n.Dict()
+
+	if dict == nil {
+		return ErrNoStreamDict
+	}
+
+	encoded := data
+
+	for i := len(filterNames) - 1; i >= 0; i-- {
+		var err error
+
+		if encoded, err = filters.Encode(filterNames[i], encoded, filters.DefaultParams()); err != nil {
+			return err
+		}
+	}
+
+	switch len(filterNames) {
+	case 0:
+		dict.Delete("Filter")
+	case 1:
+		dict.Set("Filter", NewNameNode(filterNames[0]))
+	default:
+		array := NewArrayNode()
+
+		for _, name := range filterNames {
+			array.AddChild(NewNameNode(name))
+		}
+
+		dict.Set("Filter", array)
+	}
+
+	dict.Delete("DecodeParms")
+	dict.Set("Length", NewIntegerNode(int64(len(encoded))))
+	n.SetValue(encoded)
+
+	return nil
+}
+
+// DecodeStream applies the filters given by a stream dictionary (/Filter and
+// /DecodeParms) to the data of the stream
+func DecodeStream(dict *DictNode, data []byte) ([]byte, error) {
+	names, params, err := streamFilters(dict)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, name := range names {
+		if data, err = filters.Decode(name, data, params[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// streamFilters returns the names of the filters of a stream, in the order
+// they're applied to decode it, along with the parameters of each. /Filter is
+// a name or an array of names, and /DecodeParms a dictionary or an array with
+// a dictionary (or null) for each filter.
+func streamFilters(dict *DictNode) ([]string, []filters.Params, error) {
+	filter := dict.Get("Filter")
+	decodeParms := dict.Get("DecodeParms")
+
+	if filter == nil || filter.Type() == NULL {
+		return nil, nil, nil
+	}
+
+	filterNodes := []PdfNode{filter}
+	paramNodes := []PdfNode{decodeParms}
+
+	if array, ok := filter.(*ArrayNode); ok {
+		filterNodes = array.Children()
+		paramNodes = make([]PdfNode, len(filterNodes))
+
+		switch decodeParms := decodeParms.(type) {
+		case *ArrayNode:
+			if len(decodeParms.Children()) != len(filterNodes) {
+				return nil, nil, fmt.Errorf(
+					"%w: %d filters but %d /DecodeParms",
+					ErrInvalidFilters,
+					len(filterNodes),
+					len(decodeParms.Children()),
+				)
+			}
+
+			paramNodes = decodeParms.Children()
+
+		case *DictNode:
+			// Some writers give a dictionary for a single filter in an array
+			if len(filterNodes) == 1 {
+				paramNodes[0] = decodeParms
+			}
+		}
+	}
+
+	names := []string{}
+	params := []filters.Params{}
+
+	for i, node := range filterNodes {
+		name, err := Name(node)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: /Filter: %v", ErrInvalidFilters, err)
+		}
+
+		p, err := decodeParams(paramNodes[i])
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		names = append(names, name)
+		params = append(params, p)
+	}
+
+	return names, params, nil
+}
+
+// decodeParams reads filter parameters from a /DecodeParms dictionary, using
+// the default for anything not given
+func decodeParams(node PdfNode) (filters.Params, error) {
+	params := filters.DefaultParams()
+
+	if node == nil || node.Type() == NULL {
+		return params, nil
+	}
+
+	dict, err := Dict(node)
+
+	if err != nil {
+		return params, fmt.Errorf("%w: /DecodeParms: %v", ErrInvalidFilters, err)
+	}
+
+	for key, field := range map[string]*int64{
+		"Predictor":        &params.Predictor,
+		"Colors":           &params.Colors,
+		"BitsPerComponent": &params.BitsPerComponent,
+		"Columns":          &params.Columns,
+		"EarlyChange":      &params.EarlyChange,
+	} {
+		if value, ok := dict.Get(key).(*IntegerNode); ok {
+			*field = value.Value().(int64)
+		}
+	}
+
+	return params, nil
+}
@@ -0,0 +1,212 @@
+package ast_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/filters"
+)
+
+// streamObject creates a stream object with the given dictionary entries
+func streamObject(data []byte, entries ...ast.DictEntry) *ast.StreamNode {
+	dict := ast.NewDictNode()
+
+	for _, entry := range entries {
+		dict.Set(entry.Key, entry.Value)
+	}
+
+	stream := ast.NewStreamNode(data)
+	obj := ast.NewIndirectObjectNode(1, 0)
+	obj.AddChild(dict)
+	obj.AddChild(stream)
+
+	return stream
+}
+
+func TestStreamNode_Decoded(t *testing.T) {
+	content := []byte("BT /F1 12 Tf (Hello) Tj ET")
+	compressed, _ := filters.FlateEncode(content)
+
+	stream := streamObject(compressed, ast.DictEntry{Key: "Filter", Value: ast.NewNameNode("FlateDecode")})
+	decoded, err := stream.Decoded()
+
+	if err != nil || !bytes.Equal(decoded, content) {
+		t.Errorf("Expected %q, got %q (%v)", content, decoded, err)
+	}
+
+	// A filter array applies each filter in turn
+	twice, _ := filters.FlateEncode(compressed)
+	array := ast.NewArrayNode()
+	array.AddChild(ast.NewNameNode("FlateDecode"))
+	array.AddChild(ast.NewNameNode("FlateDecode"))
+
+	stream = streamObject(twice, ast.DictEntry{Key: "Filter", Value: array})
+	decoded, err = stream.Decoded()
+
+	if err != nil || !bytes.Equal(decoded, content) {
+		t.Errorf("Expected %q, got %q (%v)", content, decoded, err)
+	}
+}
+
+func TestStreamNode_DecodedWithFilterArray(t *testing.T) {
+	content := []byte("BT /F1 12 Tf (Hello Hello Hello) Tj ET")
+
+	lateChange := filters.DefaultParams()
+	lateChange.EarlyChange = 0
+
+	lzw, _ := filters.Encode("LZWDecode", content, lateChange)
+	encoded := filters.ASCII85Encode(lzw)
+
+	array := ast.NewArrayNode()
+	array.AddChild(ast.NewNameNode("ASCII85Decode"))
+	array.AddChild(ast.NewNameNode("LZWDecode"))
+
+	lzwParams := ast.NewDictNode()
+	lzwParams.Set("EarlyChange", ast.NewIntegerNode(0))
+
+	params := ast.NewArrayNode()
+	params.AddChild(ast.NewNullNode())
+	params.AddChild(lzwParams)
+
+	stream := streamObject(
+		encoded,
+		ast.DictEntry{Key: "Filter", Value: array},
+		ast.DictEntry{Key: "DecodeParms", Value: params},
+	)
+
+	decoded, err := stream.Decoded()
+
+	if err != nil || !bytes.Equal(decoded, content) {
+		t.Errorf("Expected %q, got %q (%v)", content, decoded, err)
+	}
+}
+
+func TestStreamNode_DecodedWithPredictor(t *testing.T) {
+	compressed, _ := filters.FlateEncode([]byte{2, 1, 2, 3, 2, 1, 1, 1})
+
+	params := ast.NewDictNode()
+	params.Set("Predictor", ast.NewIntegerNode(12))
+	params.Set("Columns", ast.NewIntegerNode(3))
+
+	stream := streamObject(
+		compressed,
+		ast.DictEntry{Key: "Filter", Value: ast.NewNameNode("FlateDecode")},
+		ast.DictEntry{Key: "DecodeParms", Value: params},
+	)
+
+	decoded, err := stream.Decoded()
+
+	if expected := []byte{1, 2, 3, 2, 3, 4}; err != nil || !bytes.Equal(decoded, expected) {
+		t.Errorf("Expected %v, got %v (%v)", expected, decoded, err)
+	}
+}
+
+func TestStreamNode_DecodedFromParsedDocument(t *testing.T) {
+	root := parse(t, jsonDocument)
+	stream, err := ast.Stream(root.Object(2))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if stream.Object() != root.Object(2) || stream.Dict().Get("Length") == nil {
+		t.Errorf("Expected the stream to belong to its object")
+	}
+
+	decoded, err := stream.Decoded()
+
+	if err != nil || string(decoded) != "hello world" {
+		t.Errorf("Expected the data as it is, got %q (%v)", decoded, err)
+	}
+}
+
+func TestStreamNode_DecodedErrors(t *testing.T) {
+	if _, err := ast.NewStreamNode([]byte{}).Decoded(); !errors.Is(err, ast.ErrNoStreamDict) {
+		t.Errorf("Expected ErrNoStreamDict, got %v", err)
+	}
+
+	stream := streamObject([]byte{}, ast.DictEntry{Key: "Filter", Value: ast.NewNameNode("DCTDecode")})
+
+	if _, err := stream.Decoded(); !errors.Is(err, filters.ErrUnsupportedFilter) {
+		t.Errorf("Expected ErrUnsupportedFilter, got %v", err)
+	}
+
+	array := ast.NewArrayNode()
+	array.AddChild(ast.NewNameNode("FlateDecode"))
+	array.AddChild(ast.NewNameNode("FlateDecode"))
+	params := ast.NewArrayNode()
+	params.AddChild(ast.NewNullNode())
+
+	stream = streamObject(
+		[]byte{},
+		ast.DictEntry{Key: "Filter", Value: array},
+		ast.DictEntry{Key: "DecodeParms", Value: params},
+	)
+
+	if _, err := stream.Decoded(); !errors.Is(err, ast.ErrInvalidFilters) {
+		t.Errorf("Expected ErrInvalidFilters, got %v", err)
+	}
+
+	stream = streamObject([]byte{}, ast.DictEntry{Key: "Filter", Value: ast.NewStringNode([]byte("FlateDecode"))})
+
+	if _, err := stream.Decoded(); !errors.Is(err, ast.ErrInvalidFilters) {
+		t.Errorf("Expected ErrInvalidFilters, got %v", err)
+	}
+}
+
+func TestStreamNode_SetDecoded(t *testing.T) {
+	content := []byte("BT /F1 12 Tf (Hello) Tj ET")
+
+	stream := streamObject(
+		[]byte("stale"),
+		ast.DictEntry{Key: "Length", Value: ast.NewIntegerNode(5)},
+		ast.DictEntry{Key: "DecodeParms", Value: ast.NewDictNode()},
+	)
+
+	if err := stream.SetDecoded(content, "ASCII85Decode", "FlateDecode"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	decoded, err := stream.Decoded()
+
+	if err != nil || !bytes.Equal(decoded, content) {
+		t.Errorf("Expected %q, got %q (%v)", content, decoded, err)
+	}
+
+	dict := stream.Dict()
+
+	if length, _ := ast.Int(dict.Get("Length")); length != int64(len(stream.Value().([]byte))) {
+		t.Errorf("Expected /Length %d, got %d", len(stream.Value().([]byte)), length)
+	}
+
+	if dict.Get("DecodeParms") != nil {
+		t.Errorf("Expected /DecodeParms to be removed")
+	}
+
+	// With no filters the data is stored as it is
+	if err := stream.SetDecoded(content); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(stream.Value().([]byte), content) || dict.Get("Filter") != nil {
+		t.Errorf("Expected unfiltered data, got %q with /Filter %v", stream.Value(), dict.Get("Filter"))
+	}
+}
+
+func TestStreamNode_SetDecodedErrors(t *testing.T) {
+	if err := ast.NewStreamNode([]byte{}).SetDecoded([]byte{}); !errors.Is(err, ast.ErrNoStreamDict) {
+		t.Errorf("Expected ErrNoStreamDict, got %v", err)
+	}
+
+	stream := streamObject([]byte("data"))
+
+	if err := stream.SetDecoded([]byte{}, "DCTDecode"); !errors.Is(err, filters.ErrUnsupportedFilter) {
+		t.Errorf("Expected ErrUnsupportedFilter, got %v", err)
+	}
+
+	if string(stream.Value().([]byte)) != "data" {
+		t.Errorf("Expected the data to be unchanged, got %q", stream.Value())
+	}
+}
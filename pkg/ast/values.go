@@ -0,0 +1,179 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var ErrWrongType = errors.New("wrong node type")
+
+// Int returns the value of an integer node. Reals with a whole value are
+// accepted too, as some writers use them where an integer is expected.
+func Int(node PdfNode) (int64, error) {
+	switch node := node.(type) {
+	case *IntegerNode:
+		return node.Value().(int64), nil
+	case *FloatNode:
+		value := node.Value().(float64)
+
+		if value, ok := wholeFloat(value); ok {
+			return value, nil
+		}
+	}
+
+	return 0, wrongType(node, INTEGER.String())
+}
+
+// Float returns the value of a real node. Integers are accepted too, as
+// writers often use them where a real is expected.
+func Float(node PdfNode) (float64, error) {
+	switch node := node.(type) {
+	case *IntegerNode:
+		return float64(node.Value().(int64)), nil
+	case *FloatNode:
+		return node.Value().(float64), nil
+	}
+
+	return 0, wrongType(node, FLOAT.String())
+}
+
+// Number returns the value of an integer or real node as a float
+func Number(node PdfNode) (float64, error) {
+	switch node := node.(type) {
+	case *IntegerNode:
+		return float64(node.Value().(int64)), nil
+	case *FloatNode:
+		return node.Value().(float64), nil
+	}
+
+	return 0, wrongType(node, "a number")
+}
+
+// wholeFloat returns a real as an integer if it has a whole value in the range
+// of an int64. float64(math.MaxInt64) rounds up to 2^63, which is out of range.
+func wholeFloat(value float64) (int64, bool) {
+	if value != math.Trunc(value) || value < math.MinInt64 || value >= math.MaxInt64 {
+		return 0, false
+	}
+
+	return int64(value), true
+}
+
+// Name returns the value of a name node, without the leading slash
+func Name(node PdfNode) (string, error) {
+	if node, ok := node.(*NameNode); ok {
+		return node.Value().(string), nil
+	}
+
+	return "", wrongType(node, NAME.String())
+}
+
+// Text returns the value of a string node as text. Strings starting with a
+// UTF-16BE or UTF-8 byte order mark are decoded accordingly, otherwise they
+// are taken to be in PDFDocEncoding.
+func Text(node PdfNode) (string, error) {
+	str, ok := node.(*StringNode)
+
+	if !ok {
+		return "", wrongType(node, STRING.String())
+	}
+
+	return decodeText(str.Value().([]byte)), nil
+}
+
+// Bool returns the value of a boolean node
+func Bool(node PdfNode) (bool, error) {
+	if node, ok := node.(*BooleanNode); ok {
+		return node.Value().(bool), nil
+	}
+
+	return false, wrongType(node, BOOLEAN.String())
+}
+
+// Array returns the node as an array
+func Array(node PdfNode) (*ArrayNode, error) {
+	if node, ok := node.(*ArrayNode); ok {
+		return node, nil
+	}
+
+	return nil, wrongType(node, ARRAY.String())
+}
+
+// Dict returns the node as a dictionary
+func Dict(node PdfNode) (*DictNode, error) {
+	if node, ok := node.(*DictNode); ok {
+		return node, nil
+	}
+
+	return nil, wrongType(node, DICT.String())
+}
+
+// Stream returns the node as a stream. For an indirect object, the stream it
+// holds is returned.
+func Stream(node PdfNode) (*StreamNode, error) {
+	switch node := node.(type) {
+	case *StreamNode:
+		return node, nil
+	case *IndirectObjectNode:
+		for _, child := range node.Children() {
+			if stream, ok := child.(*StreamNode); ok {
+				return stream, nil
+			}
+		}
+	}
+
+	return nil, wrongType(node, STREAM.String())
+}
+
+// wrongType returns an error describing a node that isn't of the expected type
+func wrongType(node PdfNode, expected string) error {
+	if node == nil {
+		return fmt.Errorf("%w: expected %s, got nothing", ErrWrongType, expected)
+	}
+
+	return fmt.Errorf("%w: expected %s, got %s", ErrWrongType, expected, node.Type())
+}
+
+// PDFDocEncoding differs from Latin-1 in these ranges
+var pdfDocEncoding = map[byte]rune{
+	0x18: '˘', 0x19: 'ˇ', 0x1a: 'ˆ', 0x1b: '˙', 0x1c: '˝', 0x1d: '˛', 0x1e: '˚', 0x1f: '˜',
+	0x80: '•', 0x81: '†', 0x82: '‡', 0x83: '…', 0x84: '—', 0x85: '–', 0x86: 'ƒ', 0x87: '⁄',
+	0x88: '‹', 0x89: '›', 0x8a: '−', 0x8b: '‰', 0x8c: '„', 0x8d: '“', 0x8e: '”', 0x8f: '‘',
+	0x90: '’', 0x91: '‚', 0x92: '™', 0x93: 'ﬁ', 0x94: 'ﬂ', 0x95: 'Ł', 0x96: 'Œ', 0x97: 'Š',
+	0x98: 'Ÿ', 0x99: 'Ž', 0x9a: 'ı', 0x9b: 'ł', 0x9c: 'œ', 0x9d: 'š', 0x9e: 'ž', 0xa0: '€',
+}
+
+// decodeText decodes the bytes of a text string
+func decodeText(data []byte) string {
+	switch {
+	case len(data) >= 2 && data[0] == 0xfe && data[1] == 0xff:
+		units := []uint16{}
+
+		for i := 2; i+1 < len(data); i += 2 {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		}
+
+		return string(utf16.Decode(units))
+
+	case len(data) >= 3 && data[0] == 0xef && data[1] == 0xbb && data[2] == 0xbf:
+		if utf8.Valid(data[3:]) {
+			return string(data[3:])
+		}
+	}
+
+	runes := make([]rune, 0, len(data))
+
+	for _, b := range data {
+		if r, ok := pdfDocEncoding[b]; ok {
+			runes = append(runes, r)
+			continue
+		}
+
+		runes = append(runes, rune(b))
+	}
+
+	return string(runes)
+}
@@ -0,0 +1,155 @@
+package ast_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+)
+
+func TestInt(t *testing.T) {
+	for _, test := range []struct {
+		node     ast.PdfNode
+		expected int64
+	}{
+		{ast.NewIntegerNode(42), 42},
+		{ast.NewFloatNode(612.0), 612},
+		{ast.NewFloatNode(-3), -3},
+		{ast.NewFloatNode(-math.Pow(2, 63)), math.MinInt64},
+	} {
+		value, err := ast.Int(test.node)
+
+		if err != nil || value != test.expected {
+			t.Errorf("Expected %d, got %d (%v)", test.expected, value, err)
+		}
+	}
+
+	for _, node := range []ast.PdfNode{
+		ast.NewFloatNode(1.5),
+		ast.NewFloatNode(math.Pow(2, 63)),
+		ast.NewFloatNode(math.Inf(1)),
+		ast.NewNameNode("One"),
+		nil,
+	} {
+		if _, err := ast.Int(node); !errors.Is(err, ast.ErrWrongType) {
+			t.Errorf("Expected ErrWrongType for %v, got %v", node, err)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	if value, err := ast.Number(ast.NewIntegerNode(3)); err != nil || value != 3 {
+		t.Errorf("Expected 3, got %v (%v)", value, err)
+	}
+
+	if value, err := ast.Number(ast.NewFloatNode(0.5)); err != nil || value != 0.5 {
+		t.Errorf("Expected 0.5, got %v (%v)", value, err)
+	}
+
+	if value, err := ast.Float(ast.NewIntegerNode(3)); err != nil || value != 3 {
+		t.Errorf("Expected 3, got %v (%v)", value, err)
+	}
+
+	if _, err := ast.Float(ast.NewNameNode("3")); !errors.Is(err, ast.ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+
+	if _, err := ast.Number(ast.NewStringNode([]byte("3"))); !errors.Is(err, ast.ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestText(t *testing.T) {
+	for _, test := range []struct {
+		value    []byte
+		expected string
+	}{
+		{[]byte("Hello"), "Hello"},
+		{[]byte{0xfe, 0xff, 0x00, 'H', 0x00, 'i', 0xd8, 0x3d, 0xde, 0x00}, "Hi😀"},
+		{[]byte{0xef, 0xbb, 0xbf, 'c', 'a', 'f', 0xc3, 0xa9}, "café"},
+		{[]byte{'c', 'a', 'f', 0xe9, 0x84, 0xa0}, "café—€"},
+	} {
+		text, err := ast.Text(ast.NewStringNode(test.value))
+
+		if err != nil || text != test.expected {
+			t.Errorf("Expected %q, got %q (%v)", test.expected, text, err)
+		}
+	}
+
+	if _, err := ast.Text(ast.NewNameNode("Hello")); !errors.Is(err, ast.ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	obj := parse(t, "1 0 obj\n<< /Length 2 /Open true /Kids [] /Type /Page >>\nstream\nab\nendstream\nendobj\n").
+		Objects()[0]
+
+	dict, err := ast.Dict(obj.Children()[0])
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if name, err := ast.Name(dict.Get("Type")); err != nil || name != "Page" {
+		t.Errorf("Expected Page, got %q (%v)", name, err)
+	}
+
+	if open, err := ast.Bool(dict.Get("Open")); err != nil || !open {
+		t.Errorf("Expected true, got %v (%v)", open, err)
+	}
+
+	if _, err := ast.Array(dict.Get("Kids")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if stream, err := ast.Stream(obj); err != nil || string(stream.Value().([]byte)) != "ab" {
+		t.Errorf("Expected the stream, got %v (%v)", stream, err)
+	}
+
+	if _, err := ast.Dict(dict.Get("Missing")); !errors.Is(err, ast.ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	integer := parse(t, "1 0 obj\n5\nendobj\n").Objects()[0].Children()[0]
+
+	integer.SetValue(int64(6))
+
+	if integer.Value() != int64(6) {
+		t.Errorf("Expected 6, got %v", integer.Value())
+	}
+
+	integer.SetValue(7)
+
+	if integer.Value() != int64(7) {
+		t.Errorf("Expected an int to be stored as int64 7, got %#v", integer.Value())
+	}
+
+	integer.SetValue(8.0)
+
+	if integer.Value() != int64(8) {
+		t.Errorf("Expected a whole float to be stored as int64 8, got %#v", integer.Value())
+	}
+
+	float := ast.NewFloatNode(0.5)
+	float.SetValue(int64(2))
+
+	if float.Value() != 2.0 {
+		t.Errorf("Expected an int64 to be stored as float64 2, got %#v", float.Value())
+	}
+
+	for _, value := range []interface{}{"8", 8.5} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected a panic for %#v", value)
+				}
+			}()
+
+			integer.SetValue(value)
+		}()
+	}
+}
@@ -0,0 +1,82 @@
+package ast
+
+// Visitor has a method for each type of node, called by Visit. Methods can
+// return SkipChildren or StopWalk to control the walk, as with Walk. Embed
+// BaseVisitor to only implement the methods needed.
+type Visitor interface {
+	VisitRoot(node *RootNode, path Path) error
+	VisitBoolean(node *BooleanNode, path Path) error
+	VisitNull(node *NullNode, path Path) error
+	VisitFloat(node *FloatNode, path Path) error
+	VisitInteger(node *IntegerNode, path Path) error
+	VisitName(node *NameNode, path Path) error
+	VisitString(node *StringNode, path Path) error
+	VisitDict(node *DictNode, path Path) error
+	VisitArray(node *ArrayNode, path Path) error
+	VisitFunction(node *FunctionNode, path Path) error
+	VisitStream(node *StreamNode, path Path) error
+	VisitXRefs(node *XRefsNode, path Path) error
+	VisitTrailer(node *TrailerNode, path Path) error
+	VisitIndirectObject(node *IndirectObjectNode, path Path) error
+	VisitObjectRef(node *ObjectRefNode, path Path) error
+}
+
+// Visit walks the tree rooted at node as Walk does, calling the method of the
+// visitor for the type of each node
+func Visit(node PdfNode, v Visitor) error {
+	return Walk(node, func(node PdfNode, path Path) error {
+		switch node := node.(type) {
+		case *RootNode:
+			return v.VisitRoot(node, path)
+		case *BooleanNode:
+			return v.VisitBoolean(node, path)
+		case *NullNode:
+			return v.VisitNull(node, path)
+		case *FloatNode:
+			return v.VisitFloat(node, path)
+		case *IntegerNode:
+			return v.VisitInteger(node, path)
+		case *NameNode:
+			return v.VisitName(node, path)
+		case *StringNode:
+			return v.VisitString(node, path)
+		case *DictNode:
+			return v.VisitDict(node, path)
+		case *ArrayNode:
+			return v.VisitArray(node, path)
+		case *FunctionNode:
+			return v.VisitFunction(node, path)
+		case *StreamNode:
+			return v.VisitStream(node, path)
+		case *XRefsNode:
+			return v.VisitXRefs(node, path)
+		case *TrailerNode:
+			return v.VisitTrailer(node, path)
+		case *IndirectObjectNode:
+			return v.VisitIndirectObject(node, path)
+		case *ObjectRefNode:
+			return v.VisitObjectRef(node, path)
+		}
+
+		return nil
+	})
+}
+
+// BaseVisitor implements every Visitor method, doing nothing
+type BaseVisitor struct{}
+
+func (BaseVisitor) VisitRoot(*RootNode, Path) error                     { return nil }
+func (BaseVisitor) VisitBoolean(*BooleanNode, Path) error               { return nil }
+func (BaseVisitor) VisitNull(*NullNode, Path) error                     { return nil }
+func (BaseVisitor) VisitFloat(*FloatNode, Path) error                   { return nil }
+func (BaseVisitor) VisitInteger(*IntegerNode, Path) error               { return nil }
+func (BaseVisitor) VisitName(*NameNode, Path) error                     { return nil }
+func (BaseVisitor) VisitString(*StringNode, Path) error                 { return nil }
+func (BaseVisitor) VisitDict(*DictNode, Path) error                     { return nil }
+func (BaseVisitor) VisitArray(*ArrayNode, Path) error                   { return nil }
+func (BaseVisitor) VisitFunction(*FunctionNode, Path) error             { return nil }
+func (BaseVisitor) VisitStream(*StreamNode, Path) error                 { return nil }
+func (BaseVisitor) VisitXRefs(*XRefsNode, Path) error                   { return nil }
+func (BaseVisitor) VisitTrailer(*TrailerNode, Path) error               { return nil }
+func (BaseVisitor) VisitIndirectObject(*IndirectObjectNode, Path) error { return nil }
+func (BaseVisitor) VisitObjectRef(*ObjectRefNode, Path) error           { return nil }
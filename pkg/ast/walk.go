@@ -0,0 +1,109 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// SkipChildren can be returned by a WalkFunc (or Visitor method) to skip
+	// the children of the current node
+	SkipChildren = errors.New("skip children")
+
+	// StopWalk can be returned by a WalkFunc (or Visitor method) to stop the
+	// walk early. Walk then returns nil.
+	StopWalk = errors.New("stop walk")
+)
+
+// PathStep is a step from a node to one of its children. For a dictionary the
+// step is the key of the entry, otherwise it's the index of the child.
+type PathStep struct {
+	Key   string
+	Index int
+}
+
+// Path is the steps from the node a walk started at to the current node
+type Path []PathStep
+
+func (p Path) String() string {
+	sb := strings.Builder{}
+
+	for _, step := range p {
+		if step.Key != "" {
+			sb.WriteString("/" + step.Key)
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf("[%d]", step.Index))
+	}
+
+	return sb.String()
+}
+
+// WalkFunc is called by Walk for each node, along with the path to it
+type WalkFunc func(node PdfNode, path Path) error
+
+// Walk walks the tree rooted at node depth first, calling fn for each node
+// before its children. The keys of dictionaries are part of the path rather
+// than being visited themselves. Object references aren't followed.
+//
+// If fn returns SkipChildren the children of the node are skipped, and if it
+// returns StopWalk the walk stops. Any other error stops the walk and is
+// returned.
+func Walk(node PdfNode, fn WalkFunc) error {
+	err := walk(node, Path{}, fn)
+
+	if err == StopWalk {
+		return nil
+	}
+
+	return err
+}
+
+func walk(node PdfNode, path Path, fn WalkFunc) error {
+	if err := fn(node, path); err != nil {
+		if err == SkipChildren {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, child := range childSteps(node) {
+		// Copy the path so that fn can keep it
+		childPath := append(append(Path{}, path...), child.step)
+
+		if err := walk(child.node, childPath, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// childStep is a child of a node along with the step to it
+type childStep struct {
+	node PdfNode
+	step PathStep
+}
+
+// childSteps returns the children of a node. For a dictionary these are its
+// values, with their keys as the steps.
+func childSteps(node PdfNode) []childStep {
+	children := []childStep{}
+
+	if dict, ok := node.(*DictNode); ok {
+		for _, entry := range dict.Entries() {
+			children = append(children, childStep{entry.Value, PathStep{Key: entry.Key}})
+		}
+
+		return children
+	}
+
+	for i, child := range node.Children() {
+		children = append(children, childStep{child, PathStep{Index: i}})
+	}
+
+	return children
+}
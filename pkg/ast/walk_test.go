@@ -0,0 +1,117 @@
+package ast_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+)
+
+func TestWalk_Paths(t *testing.T) {
+	root := parse(t, document)
+	paths := []string{}
+
+	err := ast.Walk(root.Object(3), func(node ast.PdfNode, path ast.Path) error {
+		paths = append(paths, path.String()+" "+node.Type().String())
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{
+		" INDIRECT_OBJECT",
+		"[0] DICT",
+		"[0]/Type NAME",
+		"[0]/Parent OBJECT_REF",
+		"[0]/Resources DICT",
+		"[0]/Resources/Font OBJECT_REF",
+	}
+
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected paths %v, got %v", expected, paths)
+	}
+}
+
+func TestWalk_SkipChildren(t *testing.T) {
+	root := parse(t, document)
+	count := 0
+
+	err := ast.Walk(root.Object(3), func(node ast.PdfNode, path ast.Path) error {
+		count++
+
+		if len(path) == 2 && path[1].Key == "Resources" {
+			return ast.SkipChildren
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if count != 5 {
+		t.Errorf("Expected 5 nodes to be visited, got %d", count)
+	}
+}
+
+func TestWalk_StopWalk(t *testing.T) {
+	root := parse(t, document)
+	var found ast.PdfNode
+
+	err := ast.Walk(root, func(node ast.PdfNode, path ast.Path) error {
+		if node.Type() == ast.OBJECT_REF {
+			found = node
+			return ast.StopWalk
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if ref, ok := found.(*ast.ObjectRefNode); !ok || ref.Id() != 2 {
+		t.Errorf("Expected to stop at the first reference, got %v", found)
+	}
+}
+
+func TestWalk_ReturnsErrors(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	err := ast.Walk(parse(t, document), func(node ast.PdfNode, path ast.Path) error {
+		return errFailed
+	})
+
+	if err != errFailed {
+		t.Errorf("Expected the error to be returned, got %v", err)
+	}
+}
+
+// nameCollector collects the names in a tree
+type nameCollector struct {
+	ast.BaseVisitor
+	names []string
+}
+
+func (c *nameCollector) VisitName(node *ast.NameNode, path ast.Path) error {
+	c.names = append(c.names, node.Value().(string))
+	return nil
+}
+
+func TestVisit(t *testing.T) {
+	root := parse(t, document)
+	collector := &nameCollector{}
+
+	if err := ast.Visit(root.Object(2), collector); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(collector.names, []string{"Pages"}) {
+		t.Errorf("Expected [Pages], got %v", collector.names)
+	}
+}
@@ -0,0 +1,210 @@
+// Package contentstream reads and writes content streams, the sequences of
+// operators (each preceded by its operands) that describe what is drawn on a
+// page, form or pattern.
+package contentstream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/token"
+	"github.com/rgracey/pdf/pkg/tokeniser"
+)
+
+var (
+	ErrUnexpectedToken  = errors.New("unexpected token")
+	ErrUnexpectedEOF    = errors.New("unexpected end of content")
+	ErrMissingOperator  = errors.New("operands without an operator")
+	ErrInvalidOperation = errors.New("invalid operation")
+)
+
+// Operation is an operator along with its operands, e.g. 1 0 0 RG. An inline
+// image is a single operation with the BI operator and two operands: the
+// image dictionary (the entries between BI and ID) and a stream node holding
+// the image data.
+type Operation struct {
+	Operator string
+	Operands []ast.PdfNode
+}
+
+// ParseStream parses the decoded data of a stream as a content stream
+func ParseStream(stream *ast.StreamNode) ([]Operation, error) {
+	data, err := stream.Decoded()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// Parse parses decoded content stream data into its operations. Comments are
+// skipped.
+func Parse(data []byte) ([]Operation, error) {
+	p := &parser{
+		tokeniser: tokeniser.NewTokeniser(bytes.NewReader(data)).(*tokeniser.StreamTokeniser),
+		operands:  []ast.PdfNode{},
+	}
+
+	return p.parse()
+}
+
+// parser builds operations from the tokens of a content stream
+type parser struct {
+	tokeniser  *tokeniser.StreamTokeniser
+	operations []Operation
+	operands   []ast.PdfNode // Operands of the next operator
+	open       []ast.PdfNode // Arrays and dictionaries being parsed, innermost last
+	image      *ast.DictNode // The dictionary of an inline image being parsed
+}
+
+func (p *parser) parse() ([]Operation, error) {
+	for {
+		tok, err := p.tokeniser.NextToken()
+
+		if err != nil {
+			return nil, p.error(tok, err)
+		}
+
+		switch tok.Type {
+		case token.EOF:
+			if len(p.open) > 0 {
+				return nil, p.error(tok, ErrUnexpectedEOF)
+			}
+
+			if len(p.operands) > 0 {
+				return nil, p.error(tok, ErrMissingOperator)
+			}
+
+			return p.operations, nil
+
+		case token.COMMENT:
+			// Comments aren't part of the content
+
+		case token.NAME:
+			p.add(ast.NewNameNode(tok.Value.(string)))
+
+		case token.BOOLEAN:
+			p.add(ast.NewBooleanNode(tok.Value.(bool)))
+
+		case token.NULL:
+			p.add(ast.NewNullNode())
+
+		case token.NUMBER_INTEGER:
+			p.add(ast.NewIntegerNode(tok.Value.(int64)))
+
+		case token.NUMBER_FLOAT:
+			p.add(ast.NewFloatNode(tok.Value.(float64)))
+
+		case token.STRING_LITERAL:
+			p.add(ast.NewStringNode(tok.Value.([]byte)))
+
+		case token.HEX_STRING:
+			str := ast.NewHexStringNode(tok.Value.([]byte))
+			str.SetHexDigits(tok.Raw)
+			p.add(str)
+
+		case token.ARRAY_START:
+			p.open = append(p.open, ast.NewArrayNode())
+
+		case token.DICT_START:
+			p.open = append(p.open, ast.NewDictNode())
+
+		case token.ARRAY_END:
+			if err := p.close(ast.ARRAY); err != nil {
+				return nil, p.error(tok, err)
+			}
+
+		case token.DICT_END:
+			if err := p.close(ast.DICT); err != nil {
+				return nil, p.error(tok, err)
+			}
+
+		case token.KEYWORD:
+			if err := p.keyword(tok.Value.(string)); err != nil {
+				return nil, p.error(tok, err)
+			}
+
+		default:
+			return nil, p.error(tok, ErrUnexpectedToken)
+		}
+	}
+}
+
+// keyword handles an operator, or the BI and ID keywords that surround the
+// dictionary of an inline image
+func (p *parser) keyword(keyword string) error {
+	switch {
+	case keyword == "BI" && p.image == nil && len(p.open) == 0:
+		if len(p.operands) > 0 {
+			return ErrUnexpectedToken
+		}
+
+		p.image = ast.NewDictNode()
+		p.open = append(p.open, p.image)
+		return nil
+
+	case keyword == "ID" && p.image != nil && len(p.open) == 1:
+		if len(p.image.Children())%2 != 0 {
+			return ErrUnexpectedToken
+		}
+
+		tok, err := p.tokeniser.ReadInlineImage(inlineImageLength(p.image))
+
+		if err != nil {
+			return err
+		}
+
+		p.operations = append(p.operations, Operation{
+			Operator: "BI",
+			Operands: []ast.PdfNode{p.image, ast.NewStreamNode(tok.Value.([]byte))},
+		})
+
+		p.image = nil
+		p.open = p.open[:0]
+		return nil
+
+	case len(p.open) > 0:
+		// Operators can't appear inside arrays or dictionaries
+		return ErrUnexpectedToken
+	}
+
+	p.operations = append(p.operations, Operation{Operator: keyword, Operands: p.operands})
+	p.operands = []ast.PdfNode{}
+	return nil
+}
+
+// add adds an operand, or an element of the array or dictionary being parsed
+func (p *parser) add(node ast.PdfNode) {
+	if len(p.open) > 0 {
+		p.open[len(p.open)-1].AddChild(node)
+		return
+	}
+
+	p.operands = append(p.operands, node)
+}
+
+// close ends the innermost array or dictionary, which must be of the expected
+// type, and adds it to its parent
+func (p *parser) close(expected ast.Type) error {
+	if len(p.open) == 0 {
+		return ErrUnexpectedToken
+	}
+
+	node := p.open[len(p.open)-1]
+
+	if node.Type() != expected || node == p.image {
+		return ErrUnexpectedToken
+	}
+
+	p.open = p.open[:len(p.open)-1]
+	p.add(node)
+	return nil
+}
+
+// error wraps err with the offset of the offending token
+func (p *parser) error(tok token.Token, err error) error {
+	return fmt.Errorf("content stream offset %d: %w", tok.Offset, err)
+}
@@ -0,0 +1,191 @@
+package contentstream_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/contentstream"
+	"github.com/rgracey/pdf/pkg/tokeniser"
+)
+
+func TestParse(t *testing.T) {
+	operations := parse(t, "q 1 0 0 1 .5 -3. cm % a comment\n"+
+		"/P <</MCID 0>> BDC BT /F1 12 Tf [(A) -120 <42>] TJ ET EMC Q")
+
+	expectOperators(t, operations, "q", "cm", "BDC", "BT", "Tf", "TJ", "ET", "EMC", "Q")
+
+	cm := operations[1].Operands
+
+	if len(cm) != 6 || cm[4].Type() != ast.FLOAT || cm[4].Value() != 0.5 || cm[5].Value() != -3.0 {
+		t.Errorf("Expected 6 operands ending 0.5 -3.0, got %v", cm)
+	}
+
+	bdc := operations[2].Operands
+
+	if dict, ok := bdc[1].(*ast.DictNode); !ok || dict.Get("MCID").Value() != int64(0) {
+		t.Errorf("Expected a properties dictionary, got %v", bdc)
+	}
+
+	tj := operations[5].Operands[0]
+
+	if tj.Type() != ast.ARRAY || len(tj.Children()) != 3 || tj.Children()[2].Type() != ast.STRING {
+		t.Errorf("Expected an array of 3 elements, got %v", tj.Children())
+	}
+}
+
+func TestParse_InlineImages(t *testing.T) {
+	// Unfiltered image data whose length is known from its dimensions can
+	// contain anything, even the EI keyword
+	operations := parse(t, "q BI /W 4 /H 1 /BPC 8 /CS /G ID \xff EI\nEI Q")
+
+	expectOperators(t, operations, "q", "BI", "Q")
+	expectImage(t, operations[1], "W", []byte("\xff EI"))
+
+	// Otherwise the data ends at the EI keyword
+	operations = parse(t, "BI /W 1 /H 1 /F /AHx /D [1 0] ID 00>\nEI Q")
+
+	expectOperators(t, operations, "BI", "Q")
+	expectImage(t, operations[0], "D", []byte("00>"))
+
+	// A wrong length falls back to finding the EI keyword
+	operations = parse(t, "BI /L 10 ID abcEIdef EI")
+
+	expectOperators(t, operations, "BI")
+	expectImage(t, operations[0], "L", []byte("abcEIdef"))
+}
+
+func TestParse_ReturnsErrors(t *testing.T) {
+	for _, test := range []struct {
+		content  string
+		expected error
+	}{
+		{"1 0 0 RG 0.5", contentstream.ErrMissingOperator},
+		{"[1 2", contentstream.ErrUnexpectedEOF},
+		{"[1 2>> TJ", contentstream.ErrUnexpectedToken},
+		{"[1 Tc] TJ", contentstream.ErrUnexpectedToken},
+		{"1 BI /W 1 ID x EI", contentstream.ErrUnexpectedToken},
+		{"BI /W ID x EI", contentstream.ErrUnexpectedToken},
+		{"{ }", contentstream.ErrUnexpectedToken},
+		{"BI /W 1 ID abc", tokeniser.ErrUnterminatedImage},
+	} {
+		_, err := contentstream.Parse([]byte(test.content))
+
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.content, test.expected, err)
+		}
+	}
+}
+
+func TestParseStream(t *testing.T) {
+	stream := ast.NewStreamNode(nil)
+	obj := ast.NewIndirectObjectNode(1, 0)
+	obj.AddChild(ast.NewDictNode())
+	obj.AddChild(stream)
+
+	if err := stream.SetDecoded([]byte("BT (Hello) Tj ET"), "FlateDecode"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	operations, err := contentstream.ParseStream(stream)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectOperators(t, operations, "BT", "Tj", "ET")
+}
+
+func TestSerialise_RoundTrips(t *testing.T) {
+	content := "q 1 0 0 1 72 720 cm /P <</MCID 0>> BDC BT /F1 12 Tf [(A\\)) -120 <42>] TJ ET EMC\n" +
+		"BI /W 4 /H 1 /BPC 8 /CS /G ID \xff EI\nEI BI /W 1 /H 1 /F [/AHx] ID 00> EI Q"
+
+	operations := parse(t, content)
+	serialised, err := contentstream.Serialise(operations)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	reparsed := parse(t, string(serialised))
+
+	if len(reparsed) != len(operations) {
+		t.Fatalf("Expected %d operations, got %d:\n%s", len(operations), len(reparsed), serialised)
+	}
+
+	for i, op := range operations {
+		if reparsed[i].Operator != op.Operator || len(reparsed[i].Operands) != len(op.Operands) {
+			t.Errorf("Expected %v, got %v", op, reparsed[i])
+			continue
+		}
+
+		for j, operand := range op.Operands {
+			if diff := ast.Diff(operand, reparsed[i].Operands[j]); len(diff) > 0 {
+				t.Errorf("%s operand %d: unexpected differences %v", op.Operator, j, diff)
+			}
+		}
+	}
+}
+
+func TestSerialise_ReturnsErrorForInvalidOperations(t *testing.T) {
+	for _, operations := range [][]contentstream.Operation{
+		{{Operator: "BI", Operands: []ast.PdfNode{ast.NewDictNode()}}},
+		{{Operator: "BI", Operands: []ast.PdfNode{ast.NewDictNode(), ast.NewNameNode("data")}}},
+		{{Operator: "Do", Operands: []ast.PdfNode{ast.NewObjectRefNode(1, 0)}}},
+	} {
+		if _, err := contentstream.Serialise(operations); !errors.Is(err, contentstream.ErrInvalidOperation) {
+			t.Errorf("%v: expected ErrInvalidOperation, got %v", operations, err)
+		}
+	}
+}
+
+// parse parses content, failing the test on error
+func parse(t *testing.T, content string) []contentstream.Operation {
+	t.Helper()
+
+	operations, err := contentstream.Parse([]byte(content))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return operations
+}
+
+// expectOperators checks the operators of a list of operations
+func expectOperators(t *testing.T, operations []contentstream.Operation, expected ...string) {
+	t.Helper()
+
+	actual := []string{}
+
+	for _, op := range operations {
+		actual = append(actual, op.Operator)
+	}
+
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected operators %v, got %v", expected, actual)
+	}
+
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("Expected operators %v, got %v", expected, actual)
+		}
+	}
+}
+
+// expectImage checks an inline image has a dictionary with the given key and
+// the expected data
+func expectImage(t *testing.T, op contentstream.Operation, key string, data []byte) {
+	t.Helper()
+
+	dict, ok := op.Operands[0].(*ast.DictNode)
+
+	if !ok || dict.Get(key) == nil {
+		t.Errorf("Expected an image dictionary with /%s, got %v", key, op.Operands[0])
+	}
+
+	if actual := op.Operands[1].Value().([]byte); !bytes.Equal(actual, data) {
+		t.Errorf("Expected image data %q, got %q", data, actual)
+	}
+}
@@ -0,0 +1,81 @@
+package contentstream
+
+import "github.com/rgracey/pdf/pkg/ast"
+
+// inlineImageLength returns the length of the data of an inline image, or -1 if
+// it isn't known. PDF 2.0 gives the length with /L, otherwise it can only be
+// worked out for unfiltered images in a device colour space.
+func inlineImageLength(dict *ast.DictNode) int64 {
+	if length, err := ast.Int(entry(dict, "L", "Length")); err == nil {
+		return length
+	}
+
+	if filter := entry(dict, "F", "Filter"); filter != nil && filter.Type() != ast.NULL {
+		if array, ok := filter.(*ast.ArrayNode); !ok || len(array.Children()) > 0 {
+			return -1
+		}
+	}
+
+	width, err := ast.Int(entry(dict, "W", "Width"))
+
+	if err != nil {
+		return -1
+	}
+
+	height, err := ast.Int(entry(dict, "H", "Height"))
+
+	if err != nil {
+		return -1
+	}
+
+	bits, components := int64(1), int64(1)
+
+	if mask, ok := entry(dict, "IM", "ImageMask").(*ast.BooleanNode); !ok || !mask.Value().(bool) {
+		if bits, err = ast.Int(entry(dict, "BPC", "BitsPerComponent")); err != nil {
+			return -1
+		}
+
+		if components = colourComponents(entry(dict, "CS", "ColorSpace")); components < 0 {
+			return -1
+		}
+	}
+
+	// Each row starts on a byte boundary
+	return height * ((width*components*bits + 7) / 8)
+}
+
+// colourComponents returns the number of components per pixel of an inline
+// image colour space, or -1 if it isn't known, e.g. for a colour space named in
+// the page resources
+func colourComponents(space ast.PdfNode) int64 {
+	if array, ok := space.(*ast.ArrayNode); ok && len(array.Children()) > 0 {
+		space = array.Children()[0]
+	}
+
+	name, err := ast.Name(space)
+
+	if err != nil {
+		return -1
+	}
+
+	switch name {
+	case "G", "DeviceGray", "CalGray", "I", "Indexed":
+		return 1
+	case "RGB", "DeviceRGB", "CalRGB", "Lab":
+		return 3
+	case "CMYK", "DeviceCMYK":
+		return 4
+	}
+
+	return -1
+}
+
+// entry returns the value of an inline image dictionary entry given by either
+// its abbreviated or full key
+func entry(dict *ast.DictNode, abbreviation string, key string) ast.PdfNode {
+	if value := dict.Get(abbreviation); value != nil {
+		return value
+	}
+
+	return dict.Get(key)
+}
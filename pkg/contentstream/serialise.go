@@ -0,0 +1,95 @@
+package contentstream
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/serialiser"
+)
+
+// Serialise writes operations back out as content stream data, one operation
+// per line
+func Serialise(operations []Operation) ([]byte, error) {
+	s := serialiser.NewSerialiser()
+	buf := bytes.Buffer{}
+
+	for i, op := range operations {
+		var err error
+
+		if op.Operator == "BI" {
+			err = writeInlineImage(&buf, s, op)
+		} else {
+			err = writeOperation(&buf, s, op)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s): %w", i, op.Operator, err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeOperation writes the operands of an operation followed by its operator
+func writeOperation(buf *bytes.Buffer, s serialiser.Serialiser, op Operation) error {
+	for _, operand := range op.Operands {
+		if err := writeNode(buf, s, operand); err != nil {
+			return err
+		}
+
+		buf.WriteString(" ")
+	}
+
+	buf.WriteString(op.Operator)
+	buf.WriteString("\n")
+	return nil
+}
+
+// writeInlineImage writes an inline image, with the entries of its dictionary
+// between BI and ID, and its data between ID and EI
+func writeInlineImage(buf *bytes.Buffer, s serialiser.Serialiser, op Operation) error {
+	if len(op.Operands) != 2 {
+		return fmt.Errorf("%w: inline image with %d operands", ErrInvalidOperation, len(op.Operands))
+	}
+
+	dict, ok := op.Operands[0].(*ast.DictNode)
+	stream, isStream := op.Operands[1].(*ast.StreamNode)
+
+	if !ok || !isStream {
+		return fmt.Errorf("%w: inline image needs a dictionary and a stream", ErrInvalidOperation)
+	}
+
+	buf.WriteString("BI")
+
+	for _, child := range dict.Children() {
+		buf.WriteString(" ")
+
+		if err := writeNode(buf, s, child); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteString(" ID ")
+	buf.Write(stream.Value().([]byte))
+	buf.WriteString("\nEI\n")
+	return nil
+}
+
+// writeNode writes an operand. Only direct objects can appear in a content
+// stream.
+func writeNode(buf *bytes.Buffer, s serialiser.Serialiser, node ast.PdfNode) error {
+	switch node.Type() {
+	case ast.OBJECT_REF, ast.INDIRECT_OBJECT, ast.STREAM:
+		return fmt.Errorf("%w: %s operand", ErrInvalidOperation, node.Type())
+	}
+
+	serialised, err := s.Serialise(node)
+
+	if err != nil {
+		return err
+	}
+
+	buf.WriteString(serialised)
+	return nil
+}
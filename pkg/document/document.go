@@ -0,0 +1,424 @@
+// Package document implements random access to the objects in a PDF document
+package document
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/parser"
+	"github.com/rgracey/pdf/pkg/token"
+	"github.com/rgracey/pdf/pkg/tokeniser"
+)
+
+// How far from the end of the file to look for the startxref keyword, and from
+// the start of the file to look for the header
+const searchWindow = 1024
+
+var (
+	ErrStartXRefNotFound = errors.New("startxref not found")
+	ErrXRefNotFound      = errors.New("cross-reference table not found")
+	ErrTrailerNotFound   = errors.New("trailer not found")
+	ErrObjectNotFound    = errors.New("object not found")
+	ErrInvalidObject     = errors.New("invalid object")
+	ErrCircularReference = errors.New("circular reference")
+)
+
+// Document provides random access to the objects in a PDF. Rather than parsing
+// the whole file, the cross-reference table is found via the startxref keyword
+// at the end of the file, and objects are parsed on demand from the offsets it
+// gives. Parsed objects are cached.
+//
+// Both cross-reference tables and (PDF 1.5+) cross-reference streams are
+// supported, as are earlier sections chained together with /Prev. Each section
+// is a revision of the document (except that the two sections of a linearized
+// file are one), and objects are found in the newest revision that has them.
+//
+// A Document is not safe for concurrent use.
+type Document struct {
+	r         io.ReaderAt
+	size      int64
+	version   string
+	xrefs     *ast.XRefsNode
+	entries   map[int64]ast.XRefEntry // The xref entries by object number
+	trailer   *ast.DictNode
+	revisions []Revision                        // Oldest first
+	objects   map[int64]*ast.IndirectObjectNode // Parsed objects
+	loading   map[int64]bool                    // Objects currently being parsed
+}
+
+// NewDocument opens the document of the given size (in bytes), reading its
+// cross-reference table and trailer
+func NewDocument(r io.ReaderAt, size int64) (*Document, error) {
+	d := &Document{
+		r:       r,
+		size:    size,
+		xrefs:   ast.NewXRefsNode(),
+		entries: make(map[int64]ast.XRefEntry),
+		objects: make(map[int64]*ast.IndirectObjectNode),
+		loading: make(map[int64]bool),
+	}
+
+	d.version = d.readVersion()
+
+	offset, err := d.findStartXRef()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.readXRefs(offset); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Version returns the version from the document header, e.g. PDF-1.7
+func (d *Document) Version() string {
+	return d.version
+}
+
+// XRefs returns the cross-reference table of the document. Where there are
+// several cross-reference sections they are merged, with entries in later
+// sections taking precedence.
+func (d *Document) XRefs() *ast.XRefsNode {
+	return d.xrefs
+}
+
+// Trailer returns the trailer dictionary of the document
+func (d *Document) Trailer() *ast.DictNode {
+	return d.trailer
+}
+
+// Object returns the indirect object with the given object number, parsing it
+// if it hasn't been already. Objects held in object streams are unpacked from
+// their stream.
+func (d *Document) Object(id int64) (*ast.IndirectObjectNode, error) {
+	if obj, ok := d.objects[id]; ok {
+		return obj, nil
+	}
+
+	entry, ok := d.entries[id]
+
+	if !ok || entry.Type == ast.XREF_FREE {
+		return nil, fmt.Errorf("%w: %d", ErrObjectNotFound, id)
+	}
+
+	if d.loading[id] {
+		return nil, fmt.Errorf("%w: object %d", ErrCircularReference, id)
+	}
+
+	d.loading[id] = true
+	defer delete(d.loading, id)
+
+	if entry.Type == ast.XREF_COMPRESSED {
+		return d.compressedObject(entry)
+	}
+
+	obj, err := d.parseObject(entry)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.objects[id] = obj
+	return obj, nil
+}
+
+// compressedObject unpacks the object stream holding the object of the given
+// xref entry. All of the objects in the stream that the cross-reference table
+// refers to are cached, to save unpacking the stream again.
+func (d *Document) compressedObject(entry ast.XRefEntry) (*ast.IndirectObjectNode, error) {
+	stream, err := d.Object(entry.Offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := UnpackObjectStream(stream)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, obj := range objects {
+		// The stream may hold objects that have since been replaced
+		current, ok := d.entries[obj.Id()]
+
+		if !ok || current.Type != ast.XREF_COMPRESSED || current.Offset != stream.Id() {
+			continue
+		}
+
+		if _, ok := d.objects[obj.Id()]; !ok {
+			d.objects[obj.Id()] = obj
+		}
+	}
+
+	if obj, ok := d.objects[entry.Id]; ok {
+		return obj, nil
+	}
+
+	return nil, fmt.Errorf(
+		"%w: %d in object stream %d",
+		ErrObjectNotFound,
+		entry.Id,
+		entry.Offset,
+	)
+}
+
+// parseObject parses the object at the offset given by the xref entry
+func (d *Document) parseObject(entry ast.XRefEntry) (*ast.IndirectObjectNode, error) {
+	if entry.Offset < 0 || entry.Offset >= d.size {
+		return nil, fmt.Errorf(
+			"%w: object %d has offset %d outside of the document",
+			ErrInvalidObject,
+			entry.Id,
+			entry.Offset,
+		)
+	}
+
+	p := d.parserAt(entry.Offset)
+	node, err := p.Next()
+
+	if err != nil {
+		return nil, withOffset(err, entry.Offset)
+	}
+
+	obj, ok := node.(*ast.IndirectObjectNode)
+
+	if !ok || obj.Id() != entry.Id || obj.Gen() != entry.Gen {
+		return nil, fmt.Errorf(
+			"%w: expected object %d %d at offset %d",
+			ErrInvalidObject,
+			entry.Id,
+			entry.Gen,
+			entry.Offset,
+		)
+	}
+
+	obj.SetOffset(entry.Offset)
+	return obj, nil
+}
+
+// parserAt returns a parser that parses from the given offset. Stream lengths
+// given by references are resolved through the document.
+func (d *Document) parserAt(offset int64) *parser.Parser {
+	section := io.NewSectionReader(d.r, offset, d.size-offset)
+	p := parser.NewParser(tokeniser.NewTokeniser(section))
+
+	p.SetObjectLookup(func(id int64, gen int64) (*ast.IndirectObjectNode, error) {
+		obj, err := d.Object(id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if obj.Gen() != gen {
+			return nil, fmt.Errorf("%w: %d %d", ErrObjectNotFound, id, gen)
+		}
+
+		return obj, nil
+	})
+
+	return p
+}
+
+// readVersion reads the version from the header at the start of the document,
+// returning an empty string if there isn't one
+func (d *Document) readVersion() string {
+	head := d.read(0, searchWindow)
+	start := bytes.Index(head, []byte("%PDF-"))
+
+	if start < 0 {
+		return ""
+	}
+
+	version := head[start+1:]
+
+	if end := bytes.IndexAny(version, "\r\n \t"); end >= 0 {
+		version = version[:end]
+	}
+
+	return string(version)
+}
+
+// findStartXRef finds the offset of the last cross-reference section from the
+// startxref keyword at the end of the document
+func (d *Document) findStartXRef() (int64, error) {
+	tailStart := d.size - searchWindow
+
+	if tailStart < 0 {
+		tailStart = 0
+	}
+
+	tail := d.read(tailStart, searchWindow)
+	start := bytes.LastIndex(tail, []byte("startxref"))
+
+	if start < 0 {
+		return 0, ErrStartXRefNotFound
+	}
+
+	tok := tokeniser.NewTokeniser(bytes.NewReader(tail[start+len("startxref"):]))
+	offset, err := tok.NextToken()
+
+	if err != nil || offset.Type != token.NUMBER_INTEGER {
+		return 0, ErrStartXRefNotFound
+	}
+
+	return offset.Value.(int64), nil
+}
+
+// readXRefs reads the cross-reference section at the given offset, along with
+// any earlier sections it refers to
+func (d *Document) readXRefs(offset int64) error {
+	visited := make(map[int64]bool)
+
+	revisions := []Revision{}
+
+	for !visited[offset] {
+		visited[offset] = true
+
+		xrefs, trailer, err := d.readXRefSection(offset)
+
+		if err != nil {
+			return err
+		}
+
+		if d.trailer == nil {
+			d.trailer = trailer
+		}
+
+		// Files that are readable by both pre and post PDF 1.5 readers can
+		// have a cross-reference stream as well as a table. Its entries take
+		// precedence over those in the table.
+		if stm, ok := trailer.Get("XRefStm").(*ast.IntegerNode); ok {
+			stmXRefs, _, err := d.readXRefSection(stm.Value().(int64))
+
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range xrefs.Entries() {
+				if _, ok := stmXRefs.Entry(entry.Id); !ok {
+					stmXRefs.AddEntry(entry)
+				}
+			}
+
+			xrefs = stmXRefs
+		}
+
+		d.addXRefs(xrefs)
+
+		revisions = append(revisions, Revision{
+			XRefOffset: offset,
+			XRefs:      xrefs,
+			Trailer:    trailer,
+		})
+
+		prev, ok := trailer.Get("Prev").(*ast.IntegerNode)
+
+		if !ok {
+			break
+		}
+
+		offset = prev.Value().(int64)
+	}
+
+	d.setRevisions(revisions)
+	return nil
+}
+
+// readXRefSection reads the cross-reference table and trailer, or
+// cross-reference stream at the given offset
+func (d *Document) readXRefSection(offset int64) (*ast.XRefsNode, *ast.DictNode, error) {
+	if offset < 0 || offset >= d.size {
+		return nil, nil, fmt.Errorf("%w at offset %d", ErrXRefNotFound, offset)
+	}
+
+	p := d.parserAt(offset)
+	node, err := p.Next()
+
+	if err != nil {
+		return nil, nil, withOffset(err, offset)
+	}
+
+	switch node := node.(type) {
+	case *ast.XRefsNode:
+		next, err := p.Next()
+
+		if err != nil {
+			return nil, nil, withOffset(err, offset)
+		}
+
+		trailer, err := trailerDict(next)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return node, trailer, nil
+
+	case *ast.IndirectObjectNode:
+		if dict, _ := streamParts(node); dict != nil && isXRefStream(dict) {
+			return DecodeXRefStream(node)
+		}
+	}
+
+	return nil, nil, fmt.Errorf("%w at offset %d", ErrXRefNotFound, offset)
+}
+
+// addXRefs adds the entries of an (earlier) cross-reference section, ignoring
+// those for objects that already have an entry
+func (d *Document) addXRefs(xrefs *ast.XRefsNode) {
+	for _, entry := range xrefs.Entries() {
+		if _, ok := d.entries[entry.Id]; ok {
+			continue
+		}
+
+		d.entries[entry.Id] = entry
+		d.xrefs.AddEntry(entry)
+	}
+}
+
+// withOffset adjusts the offset of a parse error from a parser that started
+// part way through the document so that it is relative to the document start
+func withOffset(err error, offset int64) error {
+	var parseErr *parser.ParseError
+
+	if errors.As(err, &parseErr) {
+		parseErr.Offset += offset
+		parseErr.Token.Offset += offset
+	}
+
+	return err
+}
+
+// trailerDict returns the dictionary of a trailer node
+func trailerDict(node ast.PdfNode) (*ast.DictNode, error) {
+	if node == nil || node.Type() != ast.TRAILER {
+		return nil, ErrTrailerNotFound
+	}
+
+	for _, child := range node.Children() {
+		if dict, ok := child.(*ast.DictNode); ok {
+			return dict, nil
+		}
+	}
+
+	return nil, ErrTrailerNotFound
+}
+
+// read reads up to length bytes from the given offset, returning fewer if the
+// end of the document is reached
+func (d *Document) read(offset int64, length int64) []byte {
+	if offset+length > d.size {
+		length = d.size - offset
+	}
+
+	buf := make([]byte, length)
+	n, _ := d.r.ReadAt(buf, offset)
+	return buf[:n]
+}
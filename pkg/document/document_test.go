@@ -0,0 +1,200 @@
+package document_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/document"
+)
+
+func TestDocument_ReadsTrailer(t *testing.T) {
+	doc := openDocument(t, buildPdf(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+	))
+
+	if doc.Version() != "PDF-1.7" {
+		t.Errorf("Expected version PDF-1.7, got %v", doc.Version())
+	}
+
+	root, ok := doc.Trailer().Get("Root").(*ast.ObjectRefNode)
+
+	if !ok || root.Id() != 1 {
+		t.Errorf("Expected /Root to be 1 0 R, got %v", doc.Trailer().Get("Root"))
+	}
+
+	if len(doc.XRefs().Entries()) != 3 {
+		t.Errorf("Expected 3 xref entries, got %v", doc.XRefs().Entries())
+	}
+}
+
+func TestDocument_Object(t *testing.T) {
+	doc := openDocument(t, buildPdf(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+	))
+
+	obj, err := doc.Object(2)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if obj.Id() != 2 || obj.Gen() != 0 {
+		t.Errorf("Expected object 2 0, got %d %d", obj.Id(), obj.Gen())
+	}
+
+	dict := obj.Children()[0].(*ast.DictNode)
+
+	if dict.Get("Type").Value() != "Pages" {
+		t.Errorf("Expected /Type /Pages, got %v", dict.Get("Type").Value())
+	}
+
+	cached, _ := doc.Object(2)
+
+	if cached != obj {
+		t.Errorf("Expected the cached object to be returned")
+	}
+}
+
+func TestDocument_ObjectResolvesIndirectStreamLength(t *testing.T) {
+	doc := openDocument(t, buildPdf(
+		"<< /Length 2 0 R >>\nstream\nabc endstream def\nendstream",
+		"17",
+	))
+
+	obj, err := doc.Object(1)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	stream := obj.Children()[1]
+
+	if string(stream.Value().([]byte)) != "abc endstream def" {
+		t.Errorf("Expected stream body \"abc endstream def\", got %q", stream.Value())
+	}
+}
+
+func TestDocument_ObjectDetectsCircularLengths(t *testing.T) {
+	doc := openDocument(t, buildPdf(
+		"<< /Length 1 0 R >>\nstream\nabc\nendstream",
+	))
+
+	obj, err := doc.Object(1)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if string(obj.Children()[1].Value().([]byte)) != "abc" {
+		t.Errorf("Expected stream body \"abc\", got %q", obj.Children()[1].Value())
+	}
+}
+
+func TestDocument_ObjectIsLazy(t *testing.T) {
+	doc := openDocument(t, buildPdf(
+		"<< /Type /Catalog >>",
+		"<< /Broken [ >>",
+	))
+
+	if _, err := doc.Object(1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if _, err := doc.Object(2); err == nil {
+		t.Errorf("Expected an error for the broken object")
+	}
+}
+
+func TestDocument_ObjectReturnsErrorForMissingObjects(t *testing.T) {
+	doc := openDocument(t, buildPdf("<< >>"))
+
+	for _, id := range []int64{0, 2, -1} {
+		if _, err := doc.Object(id); !errors.Is(err, document.ErrObjectNotFound) {
+			t.Errorf("Expected ErrObjectNotFound for %d, got %v", id, err)
+		}
+	}
+}
+
+func TestDocument_ResolvesReferences(t *testing.T) {
+	doc := openDocument(t, buildPdf(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+	))
+
+	count, err := ast.NewResolver(doc).Path(doc.Trailer(), "Root/Pages/Count")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if count.Value() != int64(0) {
+		t.Errorf("Expected /Count 0, got %v", count.Value())
+	}
+}
+
+func TestNewDocument_ReturnsErrorWithoutStartXRef(t *testing.T) {
+	input := "%PDF-1.7\n1 0 obj\n<< >>\nendobj\n"
+
+	_, err := document.NewDocument(strings.NewReader(input), int64(len(input)))
+
+	if !errors.Is(err, document.ErrStartXRefNotFound) {
+		t.Errorf("Expected ErrStartXRefNotFound, got %v", err)
+	}
+}
+
+func TestNewDocument_ReturnsErrorForBadStartXRef(t *testing.T) {
+	input := "%PDF-1.7\n1 0 obj\n<< >>\nendobj\nstartxref\n9\n%%EOF"
+
+	_, err := document.NewDocument(strings.NewReader(input), int64(len(input)))
+
+	if !errors.Is(err, document.ErrXRefNotFound) {
+		t.Errorf("Expected ErrXRefNotFound, got %v", err)
+	}
+}
+
+// openDocument opens a document from a string, failing the test on error
+func openDocument(t *testing.T, input string) *document.Document {
+	t.Helper()
+
+	doc, err := document.NewDocument(strings.NewReader(input), int64(len(input)))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return doc
+}
+
+// buildPdf builds a PDF with the given objects numbered from 1, along with a
+// matching cross-reference table and a trailer with /Root 1 0 R
+func buildPdf(objects ...string) string {
+	buf := bytes.NewBufferString("%PDF-1.7\n")
+	offsets := []int{}
+
+	for i, obj := range objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f\r\n", len(objects)+1)
+
+	for _, offset := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n\r\n", offset)
+	}
+
+	fmt.Fprintf(
+		buf,
+		"trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objects)+1,
+		xref,
+	)
+
+	return buf.String()
+}
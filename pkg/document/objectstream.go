@@ -0,0 +1,183 @@
+package document
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/parser"
+	"github.com/rgracey/pdf/pkg/token"
+	"github.com/rgracey/pdf/pkg/tokeniser"
+)
+
+var ErrInvalidObjectStream = errors.New("invalid object stream")
+
+// UnpackObjectStream parses the objects held in an object stream, an indirect
+// object with a /Type /ObjStm stream dictionary. Objects in an object stream
+// always have generation 0 and have no offset in the document.
+func UnpackObjectStream(obj *ast.IndirectObjectNode) ([]*ast.IndirectObjectNode, error) {
+	dict, stream := streamParts(obj)
+
+	if dict == nil || stream == nil {
+		return nil, fmt.Errorf("%w: object %d is not a stream", ErrInvalidObjectStream, obj.Id())
+	}
+
+	if !isObjectStream(dict) {
+		return nil, fmt.Errorf("%w: object %d is not /Type /ObjStm", ErrInvalidObjectStream, obj.Id())
+	}
+
+	n, ok := dict.Get("N").(*ast.IntegerNode)
+
+	if !ok || n.Value().(int64) < 0 {
+		return nil, fmt.Errorf("%w: object %d has invalid /N", ErrInvalidObjectStream, obj.Id())
+	}
+
+	first, ok := dict.Get("First").(*ast.IntegerNode)
+
+	if !ok || first.Value().(int64) < 0 {
+		return nil, fmt.Errorf("%w: object %d has invalid /First", ErrInvalidObjectStream, obj.Id())
+	}
+
+	data, err := ast.DecodeStream(dict, stream.Value().([]byte))
+
+	if err != nil {
+		return nil, err
+	}
+
+	start := first.Value().(int64)
+
+	if start > int64(len(data)) {
+		return nil, fmt.Errorf("%w: object %d has /First past the end of the stream", ErrInvalidObjectStream, obj.Id())
+	}
+
+	header, err := readObjectStreamHeader(data[:start], n.Value().(int64))
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: object %d %v", ErrInvalidObjectStream, obj.Id(), err)
+	}
+
+	body := data[start:]
+	objects := []*ast.IndirectObjectNode{}
+
+	for i := 0; i < len(header); i += 2 {
+		id, offset := header[i], header[i+1]
+
+		// Each object runs up to the start of the next
+		end := int64(len(body))
+
+		if i+3 < len(header) && header[i+3] >= offset {
+			end = header[i+3]
+		}
+
+		if offset < 0 || offset > end || end > int64(len(body)) {
+			return nil, fmt.Errorf(
+				"%w: object %d has invalid offset %d for object %d",
+				ErrInvalidObjectStream,
+				obj.Id(),
+				offset,
+				id,
+			)
+		}
+
+		value, err := parseObjectStreamValue(body[offset:end])
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: object %d in object stream %d: %v", ErrInvalidObjectStream, id, obj.Id(), err)
+		}
+
+		unpacked := ast.NewIndirectObjectNode(id, 0)
+		unpacked.AddChild(value)
+		objects = append(objects, unpacked)
+	}
+
+	return objects, nil
+}
+
+// UnpackObjectStreams replaces each object stream in a parsed document with
+// the objects it holds, so that they can be found among the other indirect
+// objects. The order of the objects is otherwise kept.
+func UnpackObjectStreams(root ast.PdfNode) error {
+	children := append([]ast.PdfNode{}, root.Children()...)
+	unpacked := []ast.PdfNode{}
+
+	for _, child := range children {
+		obj, ok := child.(*ast.IndirectObjectNode)
+
+		if !ok {
+			unpacked = append(unpacked, child)
+			continue
+		}
+
+		if dict, _ := streamParts(obj); dict == nil || !isObjectStream(dict) {
+			unpacked = append(unpacked, child)
+			continue
+		}
+
+		objects, err := UnpackObjectStream(obj)
+
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range objects {
+			unpacked = append(unpacked, obj)
+		}
+	}
+
+	for len(root.Children()) > 0 {
+		root.RemoveChild(len(root.Children()) - 1)
+	}
+
+	for _, child := range unpacked {
+		root.AddChild(child)
+	}
+
+	return nil
+}
+
+// isObjectStream returns true if the stream dictionary is for an object stream
+func isObjectStream(dict *ast.DictNode) bool {
+	name, ok := dict.Get("Type").(*ast.NameNode)
+	return ok && name.Value() == "ObjStm"
+}
+
+// readObjectStreamHeader reads the n pairs of object numbers and offsets at the
+// start of an object stream
+func readObjectStreamHeader(data []byte, n int64) ([]int64, error) {
+	tok := tokeniser.NewTokeniser(bytes.NewReader(data))
+	header := []int64{}
+
+	for i := int64(0); i < 2*n; i++ {
+		next, err := tok.NextToken()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if next.Type != token.NUMBER_INTEGER {
+			return nil, fmt.Errorf("expected %d integers in header, got %d", 2*n, i)
+		}
+
+		header = append(header, next.Value.(int64))
+	}
+
+	return header, nil
+}
+
+// parseObjectStreamValue parses the single value that makes up an object in an
+// object stream. Unlike other indirect objects it isn't wrapped in obj and
+// endobj keywords.
+func parseObjectStreamValue(data []byte) (ast.PdfNode, error) {
+	root, err := parser.NewParser(tokeniser.NewTokeniser(bytes.NewReader(data))).Parse()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(root.Children()) != 1 {
+		return nil, fmt.Errorf("expected a single value, got %d", len(root.Children()))
+	}
+
+	return root.Children()[0], nil
+}
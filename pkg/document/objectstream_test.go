@@ -0,0 +1,204 @@
+package document_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/document"
+	"github.com/rgracey/pdf/pkg/parser"
+	"github.com/rgracey/pdf/pkg/tokeniser"
+)
+
+func TestDocument_ReadsObjectsFromObjectStreams(t *testing.T) {
+	buf := bytes.NewBufferString("%PDF-1.7\n")
+	stm := int64(buf.Len())
+
+	buf.WriteString(objectStream(1, []int64{2, 3, 4}, []string{
+		"<< /Type /Catalog /Pages 3 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+		"42",
+	}))
+
+	xref := int64(buf.Len())
+
+	buf.WriteString(xrefStream(5, "/Size 6 /Root 2 0 R", true, [][3]int64{
+		{0, 0, 255},
+		{1, stm, 0},
+		{2, 1, 0},
+		{2, 1, 1},
+		{2, 1, 2},
+		{1, xref, 0},
+	}))
+
+	fmt.Fprintf(buf, "startxref\n%d\n%%%%EOF\n", xref)
+
+	doc := openDocument(t, buf.String())
+
+	catalog, err := doc.Object(2)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if dict, ok := catalog.Children()[0].(*ast.DictNode); !ok || dict.Get("Type").Value() != "Catalog" {
+		t.Errorf("Expected the catalog, got %v", catalog.Children())
+	}
+
+	number, err := doc.Object(4)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if number.Id() != 4 || number.Gen() != 0 || number.Children()[0].Value() != int64(42) {
+		t.Errorf("Expected 4 0 obj 42, got %d %d %v", number.Id(), number.Gen(), number.Children())
+	}
+}
+
+func TestDocument_ReadsOnlyCurrentObjectsFromObjectStreams(t *testing.T) {
+	buf := bytes.NewBufferString("%PDF-1.7\n")
+	stm := int64(buf.Len())
+	buf.WriteString(objectStream(1, []int64{2, 3}, []string{"(old)", "(three)"}))
+	offset := int64(buf.Len())
+	buf.WriteString("2 0 obj\n(new)\nendobj\n")
+	xref := int64(buf.Len())
+
+	buf.WriteString(xrefStream(4, "/Size 5", false, [][3]int64{
+		{0, 0, 255},
+		{1, stm, 0},
+		{1, offset, 0},
+		{2, 1, 1},
+		{1, xref, 0},
+	}))
+
+	fmt.Fprintf(buf, "startxref\n%d\n%%%%EOF\n", xref)
+
+	doc := openDocument(t, buf.String())
+
+	// Unpacking object 3 must not replace object 2 with the copy in the stream
+	if _, err := doc.Object(3); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	obj, err := doc.Object(2)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if string(obj.Children()[0].Value().([]byte)) != "new" {
+		t.Errorf("Expected the newer object 2, got %s", obj.Children()[0].Value())
+	}
+}
+
+func TestDocument_ObjectMissingFromObjectStream(t *testing.T) {
+	buf := bytes.NewBufferString("%PDF-1.7\n")
+	stm := int64(buf.Len())
+	buf.WriteString(objectStream(1, []int64{2}, []string{"(two)"}))
+	xref := int64(buf.Len())
+
+	buf.WriteString(xrefStream(4, "/Size 5", false, [][3]int64{
+		{0, 0, 255},
+		{1, stm, 0},
+		{2, 1, 0},
+		{2, 1, 1},
+		{1, xref, 0},
+	}))
+
+	fmt.Fprintf(buf, "startxref\n%d\n%%%%EOF\n", xref)
+
+	doc := openDocument(t, buf.String())
+
+	if _, err := doc.Object(3); !errors.Is(err, document.ErrObjectNotFound) {
+		t.Errorf("Expected ErrObjectNotFound, got %v", err)
+	}
+}
+
+func TestUnpackObjectStream_InvalidHeader(t *testing.T) {
+	for _, input := range []string{
+		"1 0 obj\n<< /Type /ObjStm /N 2 /First 4 /Length 9 >>\nstream\n2 0\n(two)\nendstream\nendobj\n",
+		"1 0 obj\n<< /Type /ObjStm /N 1 /First 50 /Length 9 >>\nstream\n2 0\n(two)\nendstream\nendobj\n",
+		"1 0 obj\n<< /Type /ObjStm /N 1 /First 4 /Length 9 >>\nstream\n2 9\n(two)\nendstream\nendobj\n",
+		"1 0 obj\n<< /Type /ObjStm /First 4 /Length 9 >>\nstream\n2 0\n(two)\nendstream\nendobj\n",
+		"1 0 obj\n<< /Type /XRef /N 1 /First 4 /Length 9 >>\nstream\n2 0\n(two)\nendstream\nendobj\n",
+	} {
+		root := parse(t, input)
+
+		_, err := document.UnpackObjectStream(root.Children()[0].(*ast.IndirectObjectNode))
+
+		if !errors.Is(err, document.ErrInvalidObjectStream) {
+			t.Errorf("Expected ErrInvalidObjectStream for %q, got %v", input, err)
+		}
+	}
+}
+
+func TestUnpackObjectStreams(t *testing.T) {
+	input := "%PDF-1.7\n" +
+		"1 0 obj\n(one)\nendobj\n" +
+		objectStream(2, []int64{3, 4}, []string{"(three)", "[4 0 R]"}) +
+		"5 0 obj\n(five)\nendobj\n"
+
+	root := parse(t, input)
+
+	if err := document.UnpackObjectStreams(root); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	objects := root.(*ast.RootNode).Objects()
+	ids := []int64{}
+
+	for _, obj := range objects {
+		ids = append(ids, obj.Id())
+	}
+
+	if fmt.Sprint(ids) != "[1 3 4 5]" {
+		t.Fatalf("Expected objects [1 3 4 5], got %v", ids)
+	}
+
+	if ref, ok := objects[2].Children()[0].Children()[0].(*ast.ObjectRefNode); !ok || ref.Id() != 4 {
+		t.Errorf("Expected object 4 to be [4 0 R], got %v", objects[2].Children())
+	}
+}
+
+// objectStream returns a compressed object stream holding the given objects
+func objectStream(id int64, ids []int64, objects []string) string {
+	header := strings.Builder{}
+	body := strings.Builder{}
+
+	for i, obj := range objects {
+		fmt.Fprintf(&header, "%d %d ", ids[i], body.Len())
+		body.WriteString(obj + "\n")
+	}
+
+	data := bytes.Buffer{}
+	w := zlib.NewWriter(&data)
+	w.Write([]byte(header.String() + body.String()))
+	w.Close()
+
+	return fmt.Sprintf(
+		"%d 0 obj\n<< /Type /ObjStm /N %d /First %d /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+		id,
+		len(objects),
+		header.Len(),
+		data.Len(),
+		data.Bytes(),
+	)
+}
+
+// parse parses the input, failing the test on error
+func parse(t *testing.T, input string) ast.PdfNode {
+	t.Helper()
+
+	root, err := parser.NewParser(tokeniser.NewTokeniser(strings.NewReader(input))).Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return root
+}
@@ -0,0 +1,119 @@
+package document
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rgracey/pdf/pkg/ast"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// The marker at the end of each revision of a document
+var eofMarker = []byte("%%EOF")
+
+// Revision is one revision of a document, either the original document or an
+// incremental update appended to it. Each revision has its own cross-reference
+// section and trailer, with the trailer's /Prev entry pointing to the section
+// of the revision before.
+type Revision struct {
+	Start      int64          // Offset of the first byte of the revision
+	End        int64          // Offset just past the revision's %%EOF marker
+	XRefOffset int64          // Offset of the cross-reference section
+	XRefs      *ast.XRefsNode // Entries of the cross-reference section
+	Trailer    *ast.DictNode  // The trailer or cross-reference stream dictionary
+}
+
+// Revisions returns the revisions of the document, oldest first
+func (d *Document) Revisions() []Revision {
+	return d.revisions
+}
+
+// AtRevision returns the document as it was at the given revision, where 0 is
+// the original document. Anything appended to the document after the revision
+// is ignored.
+func (d *Document) AtRevision(index int) (*Document, error) {
+	if index < 0 || index >= len(d.revisions) {
+		return nil, fmt.Errorf("%w: %d of %d", ErrRevisionNotFound, index, len(d.revisions))
+	}
+
+	end := d.revisions[index].End
+	return NewDocument(io.NewSectionReader(d.r, 0, end), end)
+}
+
+// setRevisions sets the revisions of the document from the cross-reference
+// sections found by following the /Prev chain (so newest first), working out
+// the byte range of each
+func (d *Document) setRevisions(chain []Revision) {
+	d.revisions = make([]Revision, 0, len(chain))
+	start := int64(0)
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		revision := chain[i]
+
+		// A section within the revision before is part of it. In a linearized
+		// file, the section for the first page is near the start of the file,
+		// with a /Prev pointing forward to the main section at the end.
+		if last := len(d.revisions) - 1; last >= 0 && revision.XRefOffset < start {
+			d.revisions[last] = mergeRevision(d.revisions[last], revision)
+			continue
+		}
+
+		revision.Start = start
+		revision.End = d.findEOF(revision.XRefOffset)
+
+		d.revisions = append(d.revisions, revision)
+		start = revision.End
+	}
+}
+
+// mergeRevision returns the revision with the entries of a newer section of it
+// added. The newer section's offset and trailer are used, as the /Prev chain
+// starts there.
+func mergeRevision(revision Revision, newer Revision) Revision {
+	xrefs := ast.NewXRefsNode()
+
+	for _, entry := range revision.XRefs.Entries() {
+		xrefs.AddEntry(entry)
+	}
+
+	for _, entry := range newer.XRefs.Entries() {
+		xrefs.AddEntry(entry)
+	}
+
+	revision.XRefOffset = newer.XRefOffset
+	revision.XRefs = xrefs
+	revision.Trailer = newer.Trailer
+
+	return revision
+}
+
+// findEOF returns the offset just past the first %%EOF marker (and its end of
+// line) after the given offset, or the size of the document if there isn't one
+func (d *Document) findEOF(offset int64) int64 {
+	for pos := offset; pos < d.size; pos += searchWindow {
+		// Overlap the windows so the marker can't be split between them
+		window := d.read(pos, searchWindow+int64(len(eofMarker)))
+		index := bytes.Index(window, eofMarker)
+
+		if index < 0 {
+			continue
+		}
+
+		end := pos + int64(index+len(eofMarker))
+		eol := d.read(end, 2)
+
+		switch {
+		case bytes.HasPrefix(eol, []byte("\r\n")):
+			end += 2
+		case len(eol) > 0 && (eol[0] == '\r' || eol[0] == '\n'):
+			end++
+		}
+
+		return end
+	}
+
+	return d.size
+}
@@ -0,0 +1,231 @@
+package document_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/document"
+)
+
+func TestDocument_Revisions(t *testing.T) {
+	original := buildPdf("(one)", "(two)")
+	first := appendUpdate(original, 2, "(updated)")
+	second := appendUpdate(first, 3, "(three)")
+
+	doc := openDocument(t, second)
+	revisions := doc.Revisions()
+
+	if len(revisions) != 3 {
+		t.Fatalf("Expected 3 revisions, got %d", len(revisions))
+	}
+
+	expected := [][2]int64{
+		{0, int64(len(original))},
+		{int64(len(original)), int64(len(first))},
+		{int64(len(first)), int64(len(second))},
+	}
+
+	for i, revision := range revisions {
+		if revision.Start != expected[i][0] || revision.End != expected[i][1] {
+			t.Errorf(
+				"Expected revision %d to be [%d, %d), got [%d, %d)",
+				i,
+				expected[i][0],
+				expected[i][1],
+				revision.Start,
+				revision.End,
+			)
+		}
+	}
+
+	if revisions[0].Trailer.Get("Prev") != nil {
+		t.Errorf("Expected the original trailer to have no /Prev")
+	}
+
+	if _, ok := revisions[2].XRefs.Entry(3); !ok {
+		t.Errorf("Expected the newest revision to have an entry for object 3")
+	}
+}
+
+func TestDocument_ResolvesNewestRevision(t *testing.T) {
+	doc := openDocument(t, appendUpdate(buildPdf("(one)", "(two)"), 2, "(updated)"))
+
+	for id, expected := range map[int64]string{1: "one", 2: "updated"} {
+		obj, err := doc.Object(id)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if string(obj.Children()[0].Value().([]byte)) != expected {
+			t.Errorf("Expected object %d to be (%s), got %s", id, expected, obj.Children()[0].Value())
+		}
+	}
+}
+
+func TestDocument_AtRevision(t *testing.T) {
+	doc := openDocument(t, appendUpdate(buildPdf("(one)", "(two)"), 2, "(updated)"))
+
+	original, err := doc.AtRevision(0)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(original.Revisions()) != 1 {
+		t.Errorf("Expected 1 revision, got %d", len(original.Revisions()))
+	}
+
+	obj, err := original.Object(2)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if string(obj.Children()[0].Value().([]byte)) != "two" {
+		t.Errorf("Expected the original object 2, got %s", obj.Children()[0].Value())
+	}
+
+	if _, err := doc.AtRevision(2); !errors.Is(err, document.ErrRevisionNotFound) {
+		t.Errorf("Expected ErrRevisionNotFound, got %v", err)
+	}
+}
+
+func TestDocument_HybridXRefsAreOneRevision(t *testing.T) {
+	buf := bytes.NewBufferString("%PDF-1.7\n")
+	offset := int64(buf.Len())
+	buf.WriteString("1 0 obj\n(one)\nendobj\n")
+	stm := int64(buf.Len())
+
+	buf.WriteString(xrefStream(2, "/Size 3", false, [][3]int64{
+		{0, 0, 255},
+		{1, offset, 0},
+		{1, stm, 0},
+	}))
+
+	xref := buf.Len()
+
+	fmt.Fprintf(
+		buf,
+		"xref\n0 2\n0000000000 65535 f\r\n%010d 00000 n\r\n"+
+			"trailer\n<< /Size 3 /Root 1 0 R /XRefStm %d >>\nstartxref\n%d\n%%%%EOF\n",
+		offset,
+		stm,
+		xref,
+	)
+
+	revisions := openDocument(t, buf.String()).Revisions()
+
+	if len(revisions) != 1 {
+		t.Fatalf("Expected 1 revision, got %d", len(revisions))
+	}
+
+	// The entries of the stream and the table are combined
+	if len(revisions[0].XRefs.Entries()) != 3 {
+		t.Errorf("Expected 3 entries, got %v", revisions[0].XRefs.Entries())
+	}
+}
+
+func TestDocument_LinearizedSectionsAreOneRevision(t *testing.T) {
+	input := buildLinearizedPdf()
+	updated := appendUpdate(input, 2, "(updated)")
+	doc := openDocument(t, updated)
+	revisions := doc.Revisions()
+
+	if len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, got %d", len(revisions))
+	}
+
+	expected := [][2]int64{
+		{0, int64(len(input))},
+		{int64(len(input)), int64(len(updated))},
+	}
+
+	for i, revision := range revisions {
+		if revision.Start != expected[i][0] || revision.End != expected[i][1] {
+			t.Errorf(
+				"Expected revision %d to be [%d, %d), got [%d, %d)",
+				i,
+				expected[i][0],
+				expected[i][1],
+				revision.Start,
+				revision.End,
+			)
+		}
+	}
+
+	// The revision starts with the first page's section, and has the entries
+	// of both sections
+	if revisions[0].XRefOffset != startXRef(input) {
+		t.Errorf("Expected the first page's section at %d, got %d", startXRef(input), revisions[0].XRefOffset)
+	}
+
+	for _, id := range []int64{1, 2} {
+		if _, ok := revisions[0].XRefs.Entry(id); !ok {
+			t.Errorf("Expected the original revision to have an entry for object %d", id)
+		}
+	}
+
+	original, err := doc.AtRevision(0)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	obj, err := original.Object(2)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if string(obj.Children()[0].Value().([]byte)) != "two" {
+		t.Errorf("Expected the original object 2, got %s", obj.Children()[0].Value())
+	}
+}
+
+// buildLinearizedPdf builds a PDF laid out like a linearized file, with the
+// cross-reference section for the first page (object 2) at the start and a
+// /Prev pointing forward to the main section (object 1) at the end
+func buildLinearizedPdf() string {
+	header := "%PDF-1.7\n"
+	first := "xref\n2 1\n%010d 00000 n\r\n" +
+		"trailer\n<< /Size 3 /Root 1 0 R /Prev %010d >>\nstartxref\n0\n%%%%EOF\n"
+	objects := "2 0 obj\n(two)\nendobj\n1 0 obj\n(one)\nendobj\n"
+
+	// The offsets are written with a fixed width, so the length of the
+	// section doesn't depend on them
+	two := len(header) + len(fmt.Sprintf(first, 0, 0))
+	one := two + strings.Index(objects, "1 0 obj")
+	main := two + len(objects)
+
+	return header + fmt.Sprintf(first, two, main) + objects + fmt.Sprintf(
+		"xref\n0 2\n0000000000 65535 f\r\n%010d 00000 n\r\n"+
+			"trailer\n<< /Size 3 >>\nstartxref\n%d\n%%%%EOF\n",
+		one,
+		len(header),
+	)
+}
+
+// appendUpdate appends an incremental update to a document built with buildPdf,
+// replacing or adding the object with the given number
+func appendUpdate(input string, id int64, obj string) string {
+	buf := bytes.NewBufferString(input)
+	offset := buf.Len()
+	fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", id, obj)
+	xref := buf.Len()
+
+	fmt.Fprintf(
+		buf,
+		"xref\n%d 1\n%010d 00000 n\r\ntrailer\n<< /Size %d /Root 1 0 R /Prev %d >>\nstartxref\n%d\n%%%%EOF\n",
+		id,
+		offset,
+		id+1,
+		startXRef(input),
+		xref,
+	)
+
+	return buf.String()
+}
@@ -0,0 +1,178 @@
+package document
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/filters"
+)
+
+var (
+	ErrInvalidXRefStream = errors.New("invalid cross-reference stream")
+	ErrUnsupportedFilter = filters.ErrUnsupportedFilter
+)
+
+// DecodeXRefStream decodes the entries of a cross-reference stream, an indirect
+// object with a /Type /XRef stream dictionary. The stream dictionary doubles as
+// the trailer, so is returned too.
+func DecodeXRefStream(obj *ast.IndirectObjectNode) (*ast.XRefsNode, *ast.DictNode, error) {
+	dict, stream := streamParts(obj)
+
+	if dict == nil || stream == nil {
+		return nil, nil, fmt.Errorf("%w: object %d is not a stream", ErrInvalidXRefStream, obj.Id())
+	}
+
+	if !isXRefStream(dict) {
+		return nil, nil, fmt.Errorf("%w: object %d is not /Type /XRef", ErrInvalidXRefStream, obj.Id())
+	}
+
+	data, err := ast.DecodeStream(dict, stream.Value().([]byte))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	widths, err := integers(dict.Get("W"), 3)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: /W %v", ErrInvalidXRefStream, err)
+	}
+
+	size, ok := dict.Get("Size").(*ast.IntegerNode)
+
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: missing /Size", ErrInvalidXRefStream)
+	}
+
+	index := []int64{0, size.Value().(int64)}
+
+	if dict.Get("Index") != nil {
+		index, err = integers(dict.Get("Index"), -1)
+
+		if err != nil || len(index)%2 != 0 {
+			return nil, nil, fmt.Errorf("%w: /Index %v", ErrInvalidXRefStream, dict.Get("Index"))
+		}
+	}
+
+	entryWidth := int64(0)
+
+	for _, width := range widths {
+		if width < 0 || width > 8 {
+			return nil, nil, fmt.Errorf("%w: /W %v", ErrInvalidXRefStream, widths)
+		}
+
+		entryWidth += width
+	}
+
+	if entryWidth == 0 {
+		return nil, nil, fmt.Errorf("%w: /W %v", ErrInvalidXRefStream, widths)
+	}
+
+	xrefs := ast.NewXRefsNode()
+
+	for i := 0; i < len(index); i += 2 {
+		start, count := index[i], index[i+1]
+
+		for id := start; id < start+count; id++ {
+			if int64(len(data)) < entryWidth {
+				return nil, nil, fmt.Errorf("%w: not enough data for object %d", ErrInvalidXRefStream, id)
+			}
+
+			fields := [3]int64{}
+
+			for j, width := range widths {
+				fields[j] = readField(data[:width])
+				data = data[width:]
+			}
+
+			// The type defaults to in use if its field is omitted
+			if widths[0] == 0 {
+				fields[0] = 1
+			}
+
+			entry := ast.XRefEntry{Id: id, Offset: fields[1], Gen: fields[2]}
+
+			switch fields[0] {
+			case 0:
+				entry.Type = ast.XREF_FREE
+			case 1:
+				entry.Type = ast.XREF_IN_USE
+			case 2:
+				entry.Type = ast.XREF_COMPRESSED
+			default:
+				// Unknown types are to be treated as references to the null
+				// object, so the entry is ignored
+				continue
+			}
+
+			xrefs.AddEntry(entry)
+		}
+	}
+
+	return xrefs, dict, nil
+}
+
+// isXRefStream returns true if the stream dictionary is for a cross-reference
+// stream
+func isXRefStream(dict *ast.DictNode) bool {
+	name, ok := dict.Get("Type").(*ast.NameNode)
+	return ok && name.Value() == "XRef"
+}
+
+// streamParts returns the stream dictionary and stream of an indirect object,
+// or nil if the object isn't a stream
+func streamParts(obj *ast.IndirectObjectNode) (*ast.DictNode, *ast.StreamNode) {
+	var dict *ast.DictNode
+	var stream *ast.StreamNode
+
+	for _, child := range obj.Children() {
+		switch child := child.(type) {
+		case *ast.DictNode:
+			dict = child
+		case *ast.StreamNode:
+			stream = child
+		}
+	}
+
+	return dict, stream
+}
+
+// integers returns the values of an array of integers. If length is not -1
+// then the array must be exactly that long.
+func integers(node ast.PdfNode, length int) ([]int64, error) {
+	array, ok := node.(*ast.ArrayNode)
+
+	if !ok {
+		return nil, fmt.Errorf("expected an array, got %v", node)
+	}
+
+	if length != -1 && len(array.Children()) != length {
+		return nil, fmt.Errorf("expected %d integers, got %d", length, len(array.Children()))
+	}
+
+	values := []int64{}
+
+	for _, child := range array.Children() {
+		integer, ok := child.(*ast.IntegerNode)
+
+		if !ok {
+			return nil, fmt.Errorf("expected an integer, got %v", child.Value())
+		}
+
+		values = append(values, integer.Value().(int64))
+	}
+
+	return values, nil
+}
+
+// readField reads a big-endian unsigned integer field
+func readField(data []byte) int64 {
+	value := int64(0)
+
+	for _, b := range data {
+		value = value<<8 | int64(b)
+	}
+
+	return value
+}
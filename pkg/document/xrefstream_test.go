@@ -0,0 +1,246 @@
+package document_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+)
+
+func TestDocument_ReadsXRefStreams(t *testing.T) {
+	buf := bytes.NewBufferString("%PDF-1.7\n")
+	offsets := []int64{0}
+
+	for i, obj := range []string{"<< /Type /Catalog >>", "(two)"} {
+		offsets = append(offsets, int64(buf.Len()))
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := int64(buf.Len())
+
+	buf.WriteString(xrefStream(3, "/Size 5 /Root 1 0 R", true, [][3]int64{
+		{0, 0, 255},
+		{1, offsets[1], 0},
+		{1, offsets[2], 0},
+		{1, xref, 0},
+		{2, 3, 7}, // Object 4 is the 8th object in object stream 3
+	}))
+
+	fmt.Fprintf(buf, "startxref\n%d\n%%%%EOF\n", xref)
+
+	doc := openDocument(t, buf.String())
+
+	expected := []ast.XRefEntry{
+		{Id: 0, Offset: 0, Gen: 255, Type: ast.XREF_FREE},
+		{Id: 1, Offset: offsets[1], Gen: 0, Type: ast.XREF_IN_USE},
+		{Id: 2, Offset: offsets[2], Gen: 0, Type: ast.XREF_IN_USE},
+		{Id: 3, Offset: xref, Gen: 0, Type: ast.XREF_IN_USE},
+		{Id: 4, Offset: 3, Gen: 7, Type: ast.XREF_COMPRESSED},
+	}
+
+	for _, entry := range expected {
+		if actual, ok := doc.XRefs().Entry(entry.Id); !ok || actual != entry {
+			t.Errorf("Expected %v, got %v", entry, actual)
+		}
+	}
+
+	if doc.Trailer().Get("Root") == nil {
+		t.Errorf("Expected the stream dictionary to be used as the trailer")
+	}
+
+	obj, err := doc.Object(2)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if string(obj.Children()[0].Value().([]byte)) != "two" {
+		t.Errorf("Expected object 2 to be (two), got %v", obj.Children()[0].Value())
+	}
+}
+
+func TestDocument_ReadsXRefStreamSubsections(t *testing.T) {
+	buf := bytes.NewBufferString("%PDF-1.7\n")
+	offset := int64(buf.Len())
+	buf.WriteString("7 0 obj\n(seven)\nendobj\n")
+	xref := int64(buf.Len())
+
+	buf.WriteString(xrefStream(9, "/Size 10 /Index [0 1 7 1 9 1]", false, [][3]int64{
+		{0, 0, 255},
+		{1, offset, 0},
+		{1, xref, 0},
+	}))
+
+	fmt.Fprintf(buf, "startxref\n%d\n%%%%EOF\n", xref)
+
+	doc := openDocument(t, buf.String())
+
+	if len(doc.XRefs().Entries()) != 3 {
+		t.Errorf("Expected 3 entries, got %v", doc.XRefs().Entries())
+	}
+
+	obj, err := doc.Object(7)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if string(obj.Children()[0].Value().([]byte)) != "seven" {
+		t.Errorf("Expected object 7 to be (seven), got %v", obj.Children()[0].Value())
+	}
+}
+
+func TestDocument_FollowsPrev(t *testing.T) {
+	original := buildPdf("(one)", "(two)")
+	buf := bytes.NewBufferString(original)
+	previous := startXRef(original)
+
+	// Update object 2 and delete object 1 in an update using an xref stream
+	offset := int64(buf.Len())
+	buf.WriteString("2 0 obj\n(updated)\nendobj\n")
+	xref := int64(buf.Len())
+
+	buf.WriteString(xrefStream(
+		3,
+		fmt.Sprintf("/Size 4 /Index [1 3] /Root 1 0 R /Prev %d", previous),
+		true,
+		[][3]int64{
+			{0, 0, 1},
+			{1, offset, 0},
+			{1, xref, 0},
+		},
+	))
+
+	fmt.Fprintf(buf, "startxref\n%d\n%%%%EOF\n", xref)
+
+	doc := openDocument(t, buf.String())
+
+	obj, err := doc.Object(2)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if string(obj.Children()[0].Value().([]byte)) != "updated" {
+		t.Errorf("Expected the updated object 2, got %v", obj.Children()[0].Value())
+	}
+
+	if _, err := doc.Object(1); err == nil {
+		t.Errorf("Expected deleted object 1 to be missing")
+	}
+
+	if entry, ok := doc.XRefs().Entry(0); !ok || entry.Gen != 65535 {
+		t.Errorf("Expected entry for object 0 from the original table, got %v", entry)
+	}
+
+	if doc.Trailer().Get("Prev") == nil {
+		t.Errorf("Expected the trailer to be from the latest section")
+	}
+}
+
+func TestDocument_ReadsHybridXRefs(t *testing.T) {
+	buf := bytes.NewBufferString("%PDF-1.7\n")
+	offset := int64(buf.Len())
+	buf.WriteString("1 0 obj\n(one)\nendobj\n")
+	stm := int64(buf.Len())
+
+	buf.WriteString(xrefStream(3, "/Size 4", false, [][3]int64{
+		{0, 0, 255},
+		{1, offset, 0},
+		{2, 5, 0},
+		{1, stm, 0},
+	}))
+
+	xref := buf.Len()
+
+	// The table marks object 2 as free for readers that don't understand xref
+	// streams
+	fmt.Fprintf(
+		buf,
+		"xref\n0 3\n0000000000 65535 f\r\n%010d 00000 n\r\n0000000000 00001 f\r\n"+
+			"trailer\n<< /Size 4 /Root 1 0 R /XRefStm %d >>\nstartxref\n%d\n%%%%EOF\n",
+		offset,
+		stm,
+		xref,
+	)
+
+	doc := openDocument(t, buf.String())
+
+	if entry, ok := doc.XRefs().Entry(2); !ok || entry.Type != ast.XREF_COMPRESSED {
+		t.Errorf("Expected object 2 to be compressed, got %v", entry)
+	}
+
+	if _, err := doc.Object(1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+// xrefStream returns a cross-reference stream object with fields of widths
+// [1 4 2], optionally compressed with a PNG predictor
+func xrefStream(id int64, entries string, compress bool, fields [][3]int64) string {
+	data := bytes.Buffer{}
+	widths := []int{1, 4, 2}
+
+	for _, entry := range fields {
+		row := []byte{}
+
+		for i, width := range widths {
+			for j := width - 1; j >= 0; j-- {
+				row = append(row, byte(entry[i]>>(8*j)))
+			}
+		}
+
+		data.Write(row)
+	}
+
+	filter := ""
+	stream := data.Bytes()
+
+	if compress {
+		filter = "/Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 7 >>"
+		stream = compressWithPNGUp(stream, 7)
+	}
+
+	return fmt.Sprintf(
+		"%d 0 obj\n<< /Type /XRef %s /W [1 4 2] %s /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+		id,
+		entries,
+		filter,
+		len(stream),
+		stream,
+	)
+}
+
+// compressWithPNGUp applies the PNG Up predictor and compresses the data
+func compressWithPNGUp(data []byte, columns int) []byte {
+	predicted := []byte{}
+	previous := make([]byte, columns)
+
+	for i := 0; i < len(data); i += columns {
+		row := data[i : i+columns]
+		predicted = append(predicted, 2)
+
+		for j := range row {
+			predicted = append(predicted, row[j]-previous[j])
+		}
+
+		previous = row
+	}
+
+	buf := bytes.Buffer{}
+	w := zlib.NewWriter(&buf)
+	w.Write(predicted)
+	w.Close()
+
+	return buf.Bytes()
+}
+
+// startXRef returns the offset given by the last startxref in a document
+func startXRef(input string) int64 {
+	var offset int64
+	fmt.Sscanf(input[strings.LastIndex(input, "startxref"):], "startxref\n%d", &offset)
+	return offset
+}
@@ -0,0 +1,187 @@
+package filters
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var ErrCorruptData = errors.New("corrupt filter data")
+
+// isWhitespace returns true for the PDF whitespace characters, which the ASCII
+// filters ignore
+func isWhitespace(b byte) bool {
+	return b == 0 || b == '\t' || b == '\n' || b == '\f' || b == '\r' || b == ' '
+}
+
+// ASCIIHexDecode decodes pairs of hexadecimal digits, up to the > marking the
+// end of the data. A final odd digit is taken to be followed by a 0.
+func ASCIIHexDecode(data []byte) ([]byte, error) {
+	decoded := make([]byte, 0, len(data)/2)
+	var high byte
+	odd := false
+
+	for _, b := range data {
+		if isWhitespace(b) {
+			continue
+		}
+
+		if b == '>' {
+			break
+		}
+
+		var digit byte
+
+		switch {
+		case b >= '0' && b <= '9':
+			digit = b - '0'
+		case b >= 'a' && b <= 'f':
+			digit = b - 'a' + 10
+		case b >= 'A' && b <= 'F':
+			digit = b - 'A' + 10
+		default:
+			return nil, fmt.Errorf("%w: invalid hex digit %q", ErrCorruptData, b)
+		}
+
+		if odd {
+			decoded = append(decoded, high<<4|digit)
+		} else {
+			high = digit
+		}
+
+		odd = !odd
+	}
+
+	if odd {
+		decoded = append(decoded, high<<4)
+	}
+
+	return decoded, nil
+}
+
+// ASCIIHexEncode encodes data as hexadecimal digits, followed by the >
+// marking the end of the data
+func ASCIIHexEncode(data []byte) []byte {
+	encoded := make([]byte, hex.EncodedLen(len(data)), hex.EncodedLen(len(data))+1)
+	hex.Encode(encoded, data)
+	return append(bytes.ToUpper(encoded), '>')
+}
+
+// ASCII85Decode decodes base-85 data, up to the ~> marking the end of the
+// data. Each group of 5 characters gives 4 bytes, with z standing for 4 zero
+// bytes, and a final partial group of n characters gives n-1 bytes.
+func ASCII85Decode(data []byte) ([]byte, error) {
+	decoded := make([]byte, 0, len(data)*4/5)
+	group := make([]byte, 0, 5)
+
+	// Some writers include the <~ that starts the data in other formats
+	data = bytes.TrimLeft(data, "\x00\t\n\f\r ")
+	data = bytes.TrimPrefix(data, []byte("<~"))
+
+	for _, b := range data {
+		if isWhitespace(b) {
+			continue
+		}
+
+		if b == '~' {
+			break
+		}
+
+		if b == 'z' && len(group) == 0 {
+			decoded = append(decoded, 0, 0, 0, 0)
+			continue
+		}
+
+		if b < '!' || b > 'u' {
+			return nil, fmt.Errorf("%w: invalid base-85 character %q", ErrCorruptData, b)
+		}
+
+		group = append(group, b)
+
+		if len(group) == 5 {
+			word, err := ascii85Word(group)
+
+			if err != nil {
+				return nil, err
+			}
+
+			decoded = append(decoded, byte(word>>24), byte(word>>16), byte(word>>8), byte(word))
+			group = group[:0]
+		}
+	}
+
+	switch len(group) {
+	case 0:
+	case 1:
+		return nil, fmt.Errorf("%w: final base-85 group of 1 character", ErrCorruptData)
+	default:
+		n := len(group) - 1
+
+		// Pad with the highest digit, so the partial word rounds up
+		for len(group) < 5 {
+			group = append(group, 'u')
+		}
+
+		word, err := ascii85Word(group)
+
+		if err != nil {
+			return nil, err
+		}
+
+		decoded = append(decoded, []byte{byte(word >> 24), byte(word >> 16), byte(word >> 8), byte(word)}[:n]...)
+	}
+
+	return decoded, nil
+}
+
+// ascii85Word returns the 4 byte word given by 5 base-85 digits
+func ascii85Word(group []byte) (uint32, error) {
+	var word uint64
+
+	for _, b := range group {
+		word = word*85 + uint64(b-'!')
+	}
+
+	if word > 0xffffffff {
+		return 0, fmt.Errorf("%w: base-85 group %q out of range", ErrCorruptData, group)
+	}
+
+	return uint32(word), nil
+}
+
+// ASCII85Encode encodes data in base-85, followed by the ~> marking the end
+// of the data
+func ASCII85Encode(data []byte) []byte {
+	encoded := make([]byte, 0, (len(data)+3)/4*5+2)
+
+	for len(data) > 0 {
+		n := 4
+
+		if len(data) < n {
+			n = len(data)
+		}
+
+		chunk := make([]byte, 4)
+		copy(chunk, data[:n])
+		data = data[n:]
+
+		word := uint32(chunk[0])<<24 | uint32(chunk[1])<<16 | uint32(chunk[2])<<8 | uint32(chunk[3])
+
+		if word == 0 && n == 4 {
+			encoded = append(encoded, 'z')
+			continue
+		}
+
+		var digits [5]byte
+
+		for i := 4; i >= 0; i-- {
+			digits[i] = byte(word%85) + '!'
+			word /= 85
+		}
+
+		encoded = append(encoded, digits[:n+1]...)
+	}
+
+	return append(encoded, '~', '>')
+}
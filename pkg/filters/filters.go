@@ -0,0 +1,51 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnsupportedFilter = errors.New("unsupported filter")
+
+// Decode decodes data with the named filter (as given by /Filter, without the
+// leading slash). The abbreviated names used by inline images are accepted
+// too.
+func Decode(filter string, data []byte, params Params) ([]byte, error) {
+	switch filter {
+	case "ASCIIHexDecode", "AHx":
+		return ASCIIHexDecode(data)
+	case "ASCII85Decode", "A85":
+		return ASCII85Decode(data)
+	case "LZWDecode", "LZW":
+		return LZWDecode(data, params)
+	case "FlateDecode", "Fl":
+		return FlateDecode(data, params)
+	case "RunLengthDecode", "RL":
+		return RunLengthDecode(data)
+	}
+
+	return nil, fmt.Errorf("%w: /%s", ErrUnsupportedFilter, filter)
+}
+
+// Encode encodes data with the named filter, so that Decode with the same
+// filter and parameters gives the data back. Predictors aren't supported.
+func Encode(filter string, data []byte, params Params) ([]byte, error) {
+	if params.Predictor > 1 {
+		return nil, fmt.Errorf("%w: /%s with /Predictor %d", ErrUnsupportedFilter, filter, params.Predictor)
+	}
+
+	switch filter {
+	case "ASCIIHexDecode", "AHx":
+		return ASCIIHexEncode(data), nil
+	case "ASCII85Decode", "A85":
+		return ASCII85Encode(data), nil
+	case "LZWDecode", "LZW":
+		return LZWEncode(data, params), nil
+	case "FlateDecode", "Fl":
+		return FlateEncode(data)
+	case "RunLengthDecode", "RL":
+		return RunLengthEncode(data), nil
+	}
+
+	return nil, fmt.Errorf("%w: /%s", ErrUnsupportedFilter, filter)
+}
@@ -0,0 +1,159 @@
+package filters_test
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/filters"
+)
+
+func TestASCIIHexDecode(t *testing.T) {
+	for _, test := range []struct {
+		encoded  string
+		expected string
+	}{
+		{"48656C6C6F>", "Hello"},
+		{"48 65 6c\n6c 6f>ignored", "Hello"},
+		{"48656C6C6>", "Hell`"},
+		{">", ""},
+	} {
+		actual, err := filters.ASCIIHexDecode([]byte(test.encoded))
+
+		if err != nil || string(actual) != test.expected {
+			t.Errorf("%q: expected %q, got %q (%v)", test.encoded, test.expected, actual, err)
+		}
+	}
+
+	if _, err := filters.ASCIIHexDecode([]byte("4G>")); !errors.Is(err, filters.ErrCorruptData) {
+		t.Errorf("Expected ErrCorruptData, got %v", err)
+	}
+}
+
+func TestASCII85Decode(t *testing.T) {
+	for _, test := range []struct {
+		encoded  string
+		expected string
+	}{
+		{`87cURD]i,"Ebo7~>`, "Hello World"},
+		{"87cUR\nD]i,\"Ebo7~>", "Hello World"},
+		{"<~87cURD]i,\"Ebo7~>", "Hello World"},
+		{"z@:E^~>", "\x00\x00\x00\x00abc"},
+		{"~>", ""},
+	} {
+		actual, err := filters.ASCII85Decode([]byte(test.encoded))
+
+		if err != nil || string(actual) != test.expected {
+			t.Errorf("%q: expected %q, got %q (%v)", test.encoded, test.expected, actual, err)
+		}
+	}
+
+	for _, encoded := range []string{"87cU{~>", "s8W-\"~>", "8~>"} {
+		if _, err := filters.ASCII85Decode([]byte(encoded)); !errors.Is(err, filters.ErrCorruptData) {
+			t.Errorf("%q: expected ErrCorruptData, got %v", encoded, err)
+		}
+	}
+}
+
+func TestASCII85Encode(t *testing.T) {
+	for _, test := range []struct {
+		data     string
+		expected string
+	}{
+		{"Hello World", `87cURD]i,"Ebo7~>`},
+		{"\x00\x00\x00\x00abc", "z@:E^~>"},
+		{"", "~>"},
+	} {
+		if actual := filters.ASCII85Encode([]byte(test.data)); string(actual) != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.data, test.expected, actual)
+		}
+	}
+}
+
+func TestLZWDecode(t *testing.T) {
+	// The example from the PDF specification (7.4.4.2)
+	encoded := []byte{0x80, 0x0b, 0x60, 0x50, 0x22, 0x0c, 0x0c, 0x85, 0x01}
+
+	actual, err := filters.LZWDecode(encoded, filters.DefaultParams())
+
+	if expected := "-----A---B"; err != nil || string(actual) != expected {
+		t.Errorf("Expected %q, got %q (%v)", expected, actual, err)
+	}
+
+	// Data that ends without an end of data code
+	actual, err = filters.LZWDecode(encoded[:7], filters.DefaultParams())
+
+	if expected := "-----A---"; err != nil || string(actual) != expected {
+		t.Errorf("Expected %q, got %q (%v)", expected, actual, err)
+	}
+}
+
+func TestLZWEncode(t *testing.T) {
+	expected := []byte{0x80, 0x0b, 0x60, 0x50, 0x22, 0x0c, 0x0c, 0x85, 0x01}
+
+	if actual := filters.LZWEncode([]byte("-----A---B"), filters.DefaultParams()); !bytes.Equal(actual, expected) {
+		t.Errorf("Expected %x, got %x", expected, actual)
+	}
+}
+
+func TestRunLengthDecode(t *testing.T) {
+	encoded := []byte{0xfc, 'A', 0x01, 'B', 'C', 0x80, 'X'}
+
+	actual, err := filters.RunLengthDecode(encoded)
+
+	if expected := "AAAAABC"; err != nil || string(actual) != expected {
+		t.Errorf("Expected %q, got %q (%v)", expected, actual, err)
+	}
+
+	if actual := filters.RunLengthEncode([]byte("AAAAABC")); !bytes.Equal(actual, encoded[:6]) {
+		t.Errorf("Expected %v, got %v", encoded[:6], actual)
+	}
+
+	for _, corrupt := range [][]byte{{0x05, 'A'}, {0xff}} {
+		if _, err := filters.RunLengthDecode(corrupt); !errors.Is(err, filters.ErrCorruptData) {
+			t.Errorf("%v: expected ErrCorruptData, got %v", corrupt, err)
+		}
+	}
+}
+
+func TestEncode_RoundTrips(t *testing.T) {
+	random := make([]byte, 20000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	// Repetitive data fills the LZW table, so that it has to be cleared
+	repetitive := bytes.Repeat([]byte("BT /F1 12 Tf (Hello) Tj ET\n"), 2000)
+
+	// Long runs and long stretches without runs
+	runs := append(bytes.Repeat([]byte{7}, 300), random[:300]...)
+
+	lateChange := filters.DefaultParams()
+	lateChange.EarlyChange = 0
+
+	for _, filter := range []string{"ASCIIHexDecode", "ASCII85Decode", "LZWDecode", "FlateDecode", "RunLengthDecode"} {
+		for _, params := range []filters.Params{filters.DefaultParams(), lateChange} {
+			for _, data := range [][]byte{{}, []byte("a"), random, repetitive, runs} {
+				encoded, err := filters.Encode(filter, data, params)
+
+				if err != nil {
+					t.Fatalf("%s: unexpected error: %v", filter, err)
+				}
+
+				decoded, err := filters.Decode(filter, encoded, params)
+
+				if err != nil || !bytes.Equal(decoded, data) {
+					t.Errorf("%s: expected %d bytes to round trip, got %d (%v)", filter, len(data), len(decoded), err)
+				}
+			}
+		}
+	}
+}
+
+func TestEncode_ReturnsErrorForPredictor(t *testing.T) {
+	params := filters.DefaultParams()
+	params.Predictor = 12
+
+	if _, err := filters.Encode("FlateDecode", []byte{}, params); !errors.Is(err, filters.ErrUnsupportedFilter) {
+		t.Errorf("Expected ErrUnsupportedFilter, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+// Package filters implements the standard filters used to encode and decode
+// the data in PDF streams
+package filters
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+)
+
+// FlateDecode decompresses zlib/deflate compressed data, then reverses any
+// predictor given by the parameters
+func FlateDecode(data []byte, params Params) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	decoded, err := io.ReadAll(r)
+
+	// Plenty of writers truncate or otherwise mangle the end of the compressed
+	// data, so use as much as could be decompressed
+	if err != nil && len(decoded) == 0 {
+		return nil, err
+	}
+
+	return unpredict(decoded, params)
+}
+
+// FlateEncode compresses data using zlib/deflate. No predictor is applied.
+func FlateEncode(data []byte) ([]byte, error) {
+	buf := bytes.Buffer{}
+	w := zlib.NewWriter(&buf)
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
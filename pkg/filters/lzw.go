@@ -0,0 +1,187 @@
+package filters
+
+import "fmt"
+
+// LZW codes with special meanings
+const (
+	lzwClear    = 256
+	lzwEOD      = 257
+	lzwFirst    = 258  // The first code for a sequence
+	lzwMaxCodes = 4096 // Codes are at most 12 bits
+)
+
+// lzwWidth returns the number of bits per code for a table of the given size.
+// With early change, the width increases one code early.
+func lzwWidth(size int, earlyChange int64) int {
+	size += int(earlyChange)
+
+	switch {
+	case size < 512:
+		return 9
+	case size < 1024:
+		return 10
+	case size < 2048:
+		return 11
+	}
+
+	return 12
+}
+
+// lzwTable returns a table with the single byte sequences, and placeholders
+// for the clear and end of data codes
+func lzwTable() [][]byte {
+	table := make([][]byte, lzwFirst, lzwMaxCodes)
+
+	for i := 0; i < 256; i++ {
+		table[i] = []byte{byte(i)}
+	}
+
+	return table
+}
+
+// LZWDecode decompresses LZW compressed data (as in TIFF, with codes of 9 to
+// 12 bits, most significant bit first), then reverses any predictor given by
+// the parameters. /EarlyChange is taken from the parameters.
+func LZWDecode(data []byte, params Params) ([]byte, error) {
+	r := bitReader{data: data}
+	table := lzwTable()
+	decoded := []byte{}
+	var previous []byte
+
+	for {
+		code, ok := r.read(lzwWidth(len(table), params.EarlyChange))
+
+		if !ok || code == lzwEOD {
+			break
+		}
+
+		if code == lzwClear {
+			table = lzwTable()
+			previous = nil
+			continue
+		}
+
+		var entry []byte
+
+		switch {
+		case code < len(table):
+			entry = table[code]
+		case code == len(table) && previous != nil:
+			entry = append(append([]byte{}, previous...), previous[0])
+		default:
+			return nil, fmt.Errorf("%w: invalid LZW code %d", ErrCorruptData, code)
+		}
+
+		decoded = append(decoded, entry...)
+
+		if previous != nil && len(table) < lzwMaxCodes {
+			table = append(table, append(append([]byte{}, previous...), entry[0]))
+		}
+
+		previous = entry
+	}
+
+	return unpredict(decoded, params)
+}
+
+// LZWEncode compresses data with LZW, using the /EarlyChange from the
+// parameters. No predictor is applied.
+func LZWEncode(data []byte, params Params) []byte {
+	w := bitWriter{}
+	table := map[string]int{}
+	size := lzwFirst
+
+	reset := func() {
+		table = map[string]int{}
+		size = lzwFirst
+
+		for i := 0; i < 256; i++ {
+			table[string([]byte{byte(i)})] = i
+		}
+	}
+
+	reset()
+
+	// The decoder adds to its table a code after the encoder, so the widths
+	// are based on the size of the table one code ago
+	w.write(lzwClear, lzwWidth(size-1, params.EarlyChange))
+
+	sequence := []byte{}
+
+	for _, b := range data {
+		next := append(sequence, b)
+
+		if _, ok := table[string(next)]; ok {
+			sequence = next
+			continue
+		}
+
+		w.write(table[string(sequence)], lzwWidth(size-1, params.EarlyChange))
+		table[string(next)] = size
+		size++
+
+		// Start again before the codes get too wide for the decoder
+		if size+int(params.EarlyChange) >= lzwMaxCodes-1 {
+			w.write(lzwClear, lzwWidth(size-1, params.EarlyChange))
+			reset()
+		}
+
+		sequence = []byte{b}
+	}
+
+	if len(sequence) > 0 {
+		w.write(table[string(sequence)], lzwWidth(size-1, params.EarlyChange))
+
+		// The decoder adds the last code to its table, unless it's the first
+		// since the table was cleared
+		if size > lzwFirst {
+			size++
+		}
+	}
+
+	w.write(lzwEOD, lzwWidth(size-1, params.EarlyChange))
+	return w.bytes()
+}
+
+// bitReader reads codes of varying widths, most significant bit first
+type bitReader struct {
+	data []byte
+	bit  int // The index of the next bit to read
+}
+
+func (r *bitReader) read(width int) (int, bool) {
+	if r.bit+width > len(r.data)*8 {
+		return 0, false
+	}
+
+	code := 0
+
+	for i := 0; i < width; i++ {
+		b := r.data[r.bit/8] >> (7 - r.bit%8) & 1
+		code = code<<1 | int(b)
+		r.bit++
+	}
+
+	return code, true
+}
+
+// bitWriter writes codes of varying widths, most significant bit first
+type bitWriter struct {
+	data []byte
+	bit  int // The number of bits written
+}
+
+func (w *bitWriter) write(code int, width int) {
+	for i := width - 1; i >= 0; i-- {
+		if w.bit%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+
+		w.data[len(w.data)-1] |= byte(code>>i&1) << (7 - w.bit%8)
+		w.bit++
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	return w.data
+}
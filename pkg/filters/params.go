@@ -0,0 +1,22 @@
+package filters
+
+// Params are the parameters (from a /DecodeParms dictionary) that control how
+// a filter decodes its data
+type Params struct {
+	Predictor        int64
+	Colors           int64
+	BitsPerComponent int64
+	Columns          int64
+	EarlyChange      int64 // For LZWDecode, 1 to increase the code width early
+}
+
+// DefaultParams returns the parameters used when a stream has no /DecodeParms
+func DefaultParams() Params {
+	return Params{
+		Predictor:        1,
+		Colors:           1,
+		BitsPerComponent: 8,
+		Columns:          1,
+		EarlyChange:      1,
+	}
+}
@@ -0,0 +1,219 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidPredictor = errors.New("invalid predictor")
+
+// PNG predictor algorithms, given by the first byte of each row
+const (
+	pngNone = iota
+	pngSub
+	pngUp
+	pngAverage
+	pngPaeth
+)
+
+// unpredict reverses the predictor given by the parameters, if any
+func unpredict(data []byte, params Params) ([]byte, error) {
+	switch {
+	case params.Predictor <= 1:
+		return data, nil
+
+	case params.Predictor == 2:
+		return unpredictTIFF(data, params)
+
+	case params.Predictor >= 10 && params.Predictor <= 15:
+		return unpredictPNG(data, params)
+	}
+
+	return nil, fmt.Errorf("%w: %d", ErrInvalidPredictor, params.Predictor)
+}
+
+// unpredictPNG reverses the PNG predictors (10-15). Each row is prefixed by a
+// byte giving the algorithm used for that row, so the specific predictor in the
+// parameters doesn't matter.
+func unpredictPNG(data []byte, params Params) ([]byte, error) {
+	bpp, rowLength, err := rowSize(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows longer than the data can only be partial, so don't allocate more
+	// than is needed for them
+	if rowLength > len(data) {
+		rowLength = len(data)
+	}
+
+	decoded := make([]byte, 0, len(data))
+	previous := make([]byte, rowLength)
+
+	for len(data) > 0 {
+		algorithm := data[0]
+		data = data[1:]
+
+		// The last row may be short if the data has been truncated
+		n := rowLength
+
+		if n > len(data) {
+			n = len(data)
+		}
+
+		row := make([]byte, rowLength)
+		copy(row, data[:n])
+		data = data[n:]
+
+		for i := 0; i < rowLength; i++ {
+			var left, upLeft byte
+			up := previous[i]
+
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = previous[i-bpp]
+			}
+
+			switch algorithm {
+			case pngNone:
+			case pngSub:
+				row[i] += left
+			case pngUp:
+				row[i] += up
+			case pngAverage:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case pngPaeth:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("%w: unknown PNG algorithm %d", ErrInvalidPredictor, algorithm)
+			}
+		}
+
+		decoded = append(decoded, row[:n]...)
+		previous = row
+	}
+
+	return decoded, nil
+}
+
+// unpredictTIFF reverses TIFF predictor 2, where each component of a pixel is
+// stored as the difference from the same component of the pixel to its left.
+// Components can be 1, 2, 4, 8 or 16 bits.
+func unpredictTIFF(data []byte, params Params) ([]byte, error) {
+	_, rowLength, err := rowSize(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bpc := int(params.BitsPerComponent)
+
+	if bpc != 1 && bpc != 2 && bpc != 4 && bpc != 8 && bpc != 16 {
+		return nil, fmt.Errorf("%w: /BitsPerComponent %d", ErrInvalidPredictor, bpc)
+	}
+
+	decoded := append([]byte{}, data...)
+	colors := int(params.Colors)
+	components := colors * int(params.Columns)
+	mask := 1<<bpc - 1
+
+	for start := 0; start < len(decoded); start += rowLength {
+		end := start + rowLength
+
+		// The last row may be short if the data has been truncated
+		if end > len(decoded) {
+			end = len(decoded)
+		}
+
+		row := decoded[start:end]
+
+		for i := colors; i < components && (i+1)*bpc <= len(row)*8; i++ {
+			value := component(row, i, bpc) + component(row, i-colors, bpc)
+			setComponent(row, i, bpc, value&mask)
+		}
+	}
+
+	return decoded, nil
+}
+
+// component returns the i-th component of a row, of the given number of bits
+func component(row []byte, i int, bpc int) int {
+	switch bpc {
+	case 8:
+		return int(row[i])
+	case 16:
+		return int(row[2*i])<<8 | int(row[2*i+1])
+	}
+
+	bit := i * bpc
+	shift := 8 - bpc - bit%8
+	return int(row[bit/8]>>shift) & (1<<bpc - 1)
+}
+
+// setComponent sets the i-th component of a row, of the given number of bits
+func setComponent(row []byte, i int, bpc int, value int) {
+	switch bpc {
+	case 8:
+		row[i] = byte(value)
+		return
+	case 16:
+		row[2*i] = byte(value >> 8)
+		row[2*i+1] = byte(value)
+		return
+	}
+
+	bit := i * bpc
+	shift := 8 - bpc - bit%8
+	mask := byte(1<<bpc-1) << shift
+	row[bit/8] = row[bit/8]&^mask | byte(value)<<shift
+}
+
+// rowSize returns the number of bytes per pixel (at least 1) and per row for
+// the given parameters
+func rowSize(params Params) (int, int, error) {
+	if params.Colors < 1 || params.BitsPerComponent < 1 || params.Columns < 1 {
+		return 0, 0, fmt.Errorf(
+			"%w: /Colors %d /BitsPerComponent %d /Columns %d",
+			ErrInvalidPredictor,
+			params.Colors,
+			params.BitsPerComponent,
+			params.Columns,
+		)
+	}
+
+	bitsPerPixel := params.Colors * params.BitsPerComponent
+	bpp := int((bitsPerPixel + 7) / 8)
+	rowLength := int((bitsPerPixel*params.Columns + 7) / 8)
+
+	if bpp < 1 || rowLength < 1 {
+		return 0, 0, fmt.Errorf("%w: row size overflows", ErrInvalidPredictor)
+	}
+
+	return bpp, rowLength, nil
+}
+
+// paeth is the Paeth predictor function from the PNG specification
+func paeth(left byte, up byte, upLeft byte) byte {
+	p := int(left) + int(up) - int(upLeft)
+	pa := abs(p - int(left))
+	pb := abs(p - int(up))
+	pc := abs(p - int(upLeft))
+
+	switch {
+	case pa <= pb && pa <= pc:
+		return left
+	case pb <= pc:
+		return up
+	}
+
+	return upLeft
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
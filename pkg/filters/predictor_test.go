@@ -0,0 +1,166 @@
+package filters_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/filters"
+)
+
+func TestFlateDecode_PNGPredictors(t *testing.T) {
+	predicted := []byte{
+		1, 1, 1, 1, // Sub
+		2, 1, 1, 1, // Up
+		3, 2, 2, 2, // Average
+		4, 0, 0, 0, // Paeth
+		0, 9, 9, 9, // None
+	}
+
+	expected := []byte{
+		1, 2, 3,
+		2, 3, 4,
+		3, 5, 6,
+		3, 5, 6,
+		9, 9, 9,
+	}
+
+	params := filters.DefaultParams()
+	params.Predictor = 15
+	params.Columns = 3
+
+	actual, err := filters.FlateDecode(compress(predicted), params)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestFlateDecode_PNGPredictorWithMultipleBytesPerPixel(t *testing.T) {
+	predicted := []byte{
+		1, 10, 20, 1, 2, // Sub, each pixel is 2 bytes
+	}
+
+	params := filters.DefaultParams()
+	params.Predictor = 11
+	params.Colors = 2
+	params.Columns = 2
+
+	actual, err := filters.FlateDecode(compress(predicted), params)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if expected := []byte{10, 20, 11, 22}; !bytes.Equal(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestFlateDecode_ReturnsErrorForInvalidPredictor(t *testing.T) {
+	params := filters.DefaultParams()
+	params.Predictor = 7
+
+	_, err := filters.FlateDecode(compress([]byte{1, 2, 3}), params)
+
+	if !errors.Is(err, filters.ErrInvalidPredictor) {
+		t.Errorf("Expected ErrInvalidPredictor, got %v", err)
+	}
+}
+
+func TestFlateEncode_RoundTrips(t *testing.T) {
+	data := []byte("BT /F1 24 Tf 100 100 Td (Hello World) Tj ET")
+	encoded, err := filters.FlateEncode(data)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	decoded, err := filters.FlateDecode(encoded, filters.DefaultParams())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(decoded, data) {
+		t.Errorf("Expected %q, got %q", data, decoded)
+	}
+}
+
+// compress compresses data with zlib
+func compress(data []byte) []byte {
+	buf := bytes.Buffer{}
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+
+	return buf.Bytes()
+}
+
+func TestFlateDecode_TIFFPredictor(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		params    filters.Params
+		predicted []byte
+		expected  []byte
+	}{
+		{
+			"8 bits, RGB",
+			filters.Params{Predictor: 2, Colors: 3, BitsPerComponent: 8, Columns: 2},
+			[]byte{10, 20, 30, 1, 2, 253, 5, 5, 5, 0, 0, 0},
+			[]byte{10, 20, 30, 11, 22, 27, 5, 5, 5, 5, 5, 5},
+		},
+		{
+			"16 bits",
+			filters.Params{Predictor: 2, Colors: 1, BitsPerComponent: 16, Columns: 3},
+			[]byte{0x01, 0x00, 0x00, 0xff, 0xff, 0x02},
+			[]byte{0x01, 0x00, 0x01, 0xff, 0x01, 0x01},
+		},
+		{
+			"4 bits",
+			filters.Params{Predictor: 2, Colors: 1, BitsPerComponent: 4, Columns: 4},
+			[]byte{0x31, 0x1f},
+			[]byte{0x34, 0x54},
+		},
+		{
+			"1 bit, with padding",
+			filters.Params{Predictor: 2, Colors: 1, BitsPerComponent: 1, Columns: 3},
+			[]byte{0b10000000, 0b01000000},
+			[]byte{0b11100000, 0b01100000},
+		},
+	} {
+		actual, err := filters.FlateDecode(compress(test.predicted), test.params)
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		if !bytes.Equal(actual, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestFlateDecode_TIFFPredictorWithInvalidBitsPerComponent(t *testing.T) {
+	params := filters.Params{Predictor: 2, Colors: 1, BitsPerComponent: 3, Columns: 8}
+
+	_, err := filters.FlateDecode(compress([]byte{1, 2, 3}), params)
+
+	if !errors.Is(err, filters.ErrInvalidPredictor) {
+		t.Errorf("Expected ErrInvalidPredictor, got %v", err)
+	}
+}
+
+func TestDecode_UnsupportedFilter(t *testing.T) {
+	_, err := filters.Decode("JBIG2Decode", []byte{}, filters.DefaultParams())
+
+	if !errors.Is(err, filters.ErrUnsupportedFilter) || err.Error() != "unsupported filter: /JBIG2Decode" {
+		t.Errorf("Expected ErrUnsupportedFilter, got %v", err)
+	}
+}
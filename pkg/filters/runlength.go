@@ -0,0 +1,85 @@
+package filters
+
+import "fmt"
+
+// The length byte marking the end of run-length encoded data
+const runLengthEOD = 128
+
+// RunLengthDecode decodes run-length encoded data. A length byte of 0 to 127
+// is followed by that many bytes plus one to copy, and a length byte of 129 to
+// 255 by a single byte to repeat 257 minus the length times. 128 marks the end
+// of the data.
+func RunLengthDecode(data []byte) ([]byte, error) {
+	decoded := []byte{}
+
+	for i := 0; i < len(data); {
+		length := int(data[i])
+		i++
+
+		switch {
+		case length == runLengthEOD:
+			return decoded, nil
+
+		case length < runLengthEOD:
+			if i+length+1 > len(data) {
+				return nil, fmt.Errorf("%w: run of %d bytes past the end of the data", ErrCorruptData, length+1)
+			}
+
+			decoded = append(decoded, data[i:i+length+1]...)
+			i += length + 1
+
+		default:
+			if i >= len(data) {
+				return nil, fmt.Errorf("%w: repeated byte past the end of the data", ErrCorruptData)
+			}
+
+			for n := 0; n < 257-length; n++ {
+				decoded = append(decoded, data[i])
+			}
+
+			i++
+		}
+	}
+
+	return decoded, nil
+}
+
+// RunLengthEncode run-length encodes data, repeating runs of two or more of
+// the same byte and copying everything else
+func RunLengthEncode(data []byte) []byte {
+	encoded := []byte{}
+	literal := []byte{}
+
+	flush := func() {
+		if len(literal) > 0 {
+			encoded = append(encoded, byte(len(literal)-1))
+			encoded = append(encoded, literal...)
+			literal = literal[:0]
+		}
+	}
+
+	for i := 0; i < len(data); {
+		run := 1
+
+		for i+run < len(data) && data[i+run] == data[i] && run < 128 {
+			run++
+		}
+
+		if run > 1 {
+			flush()
+			encoded = append(encoded, byte(257-run), data[i])
+			i += run
+			continue
+		}
+
+		literal = append(literal, data[i])
+		i++
+
+		if len(literal) == 128 {
+			flush()
+		}
+	}
+
+	flush()
+	return append(encoded, runLengthEOD)
+}
@@ -0,0 +1,69 @@
+package marshal
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Name is a string marshalled as a PDF name rather than a string, e.g. for
+// elements of an array of names
+type Name string
+
+// field is a struct field mapped to a dictionary key
+type field struct {
+	key      string
+	index    []int // The index of the field, as for reflect.Value.FieldByIndex
+	optional bool  // Whether the key may be missing, and is omitted if zero
+	name     bool  // Whether a string is a name rather than a string
+}
+
+// structFields returns the fields of a struct type that map to dictionary
+// keys, in order. Fields are tagged `pdf:"Key,optional,name"`, and untagged
+// exported fields use the field name as the key. A tag of "-" skips the field.
+// The fields of untagged embedded structs are treated as fields of the outer
+// struct.
+func structFields(t reflect.Type) []field {
+	fields := []field{}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, tagged := sf.Tag.Lookup("pdf")
+
+		if tag == "-" {
+			continue
+		}
+
+		if sf.Anonymous && !tagged && sf.Type.Kind() == reflect.Struct {
+			for _, embedded := range structFields(sf.Type) {
+				embedded.index = append([]int{i}, embedded.index...)
+				fields = append(fields, embedded)
+			}
+
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		f := field{key: parts[0], index: []int{i}}
+
+		if f.key == "" {
+			f.key = sf.Name
+		}
+
+		for _, option := range parts[1:] {
+			switch option {
+			case "optional":
+				f.optional = true
+			case "name":
+				f.name = true
+			}
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields
+}
@@ -0,0 +1,228 @@
+// Package marshal maps between PDF objects and Go values, in the style of
+// encoding/json. Structs map to dictionaries, slices and arrays to arrays,
+// maps with string keys to dictionaries, and Go primitives to their PDF
+// equivalents. Fields of type ast.PdfNode (or a node type) hold nodes as
+// they are.
+package marshal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"unicode/utf16"
+
+	"github.com/rgracey/pdf/pkg/ast"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported type")
+	ErrMissingKey      = errors.New("missing key")
+	ErrOutOfRange      = errors.New("value out of range")
+)
+
+// Error is an error marshalling or unmarshalling a value, along with the path
+// of the value from the top level one, e.g. /Resources/Font/F1/Subtype
+type Error struct {
+	Path ast.Path
+	Err  error
+}
+
+func (e *Error) Error() string {
+	path := e.Path.String()
+
+	if path == "" {
+		path = "/"
+	}
+
+	return fmt.Sprintf("%s: %v", path, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var (
+	nodeType = reflect.TypeOf((*ast.PdfNode)(nil)).Elem()
+	nameType = reflect.TypeOf(Name(""))
+)
+
+// Marshal returns the PDF object for a Go value. Structs become dictionaries
+// with keys in field order, leaving out optional fields with a zero value.
+// Strings become strings unless tagged as names or of type Name, and nil
+// pointers become null. To refer to an indirect object, use a field of type
+// *ast.ObjectRefNode.
+func Marshal(v interface{}) (ast.PdfNode, error) {
+	e := &encoder{}
+	return e.encode(reflect.ValueOf(v), false)
+}
+
+// encoder encodes Go values, keeping track of the path to the current one
+type encoder struct {
+	path ast.Path
+}
+
+func (e *encoder) fail(err error) error {
+	return &Error{append(ast.Path{}, e.path...), err}
+}
+
+func (e *encoder) encode(v reflect.Value, name bool) (ast.PdfNode, error) {
+	if !v.IsValid() {
+		return ast.NewNullNode(), nil
+	}
+
+	if v.Type().Implements(nodeType) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return ast.NewNullNode(), nil
+		}
+
+		return v.Interface().(ast.PdfNode), nil
+	}
+
+	if v.Type() == nameType {
+		name = true
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return ast.NewNullNode(), nil
+		}
+
+		return e.encode(v.Elem(), name)
+
+	case reflect.Bool:
+		return ast.NewBooleanNode(v.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ast.NewIntegerNode(v.Int()), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, e.fail(fmt.Errorf("%w: %d", ErrOutOfRange, v.Uint()))
+		}
+
+		return ast.NewIntegerNode(int64(v.Uint())), nil
+
+	case reflect.Float32, reflect.Float64:
+		return ast.NewFloatNode(v.Float()), nil
+
+	case reflect.String:
+		if name {
+			return ast.NewNameNode(v.String()), nil
+		}
+
+		return ast.NewStringNode(encodeText(v.String())), nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return ast.NewStringNode(bytesOf(v)), nil
+		}
+
+		array := ast.NewArrayNode()
+
+		for i := 0; i < v.Len(); i++ {
+			e.path = append(e.path, ast.PathStep{Index: i})
+			element, err := e.encode(v.Index(i), name)
+			e.path = e.path[:len(e.path)-1]
+
+			if err != nil {
+				return nil, err
+			}
+
+			array.AddChild(element)
+		}
+
+		return array, nil
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, e.fail(fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type()))
+		}
+
+		keys := []string{}
+
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+
+		sort.Strings(keys)
+		dict := ast.NewDictNode()
+
+		for _, key := range keys {
+			value := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+
+			if err := e.encodeEntry(dict, key, value, name); err != nil {
+				return nil, err
+			}
+		}
+
+		return dict, nil
+
+	case reflect.Struct:
+		dict := ast.NewDictNode()
+
+		for _, f := range structFields(v.Type()) {
+			value := v.FieldByIndex(f.index)
+
+			if f.optional && value.IsZero() {
+				continue
+			}
+
+			if err := e.encodeEntry(dict, f.key, value, f.name); err != nil {
+				return nil, err
+			}
+		}
+
+		return dict, nil
+	}
+
+	return nil, e.fail(fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type()))
+}
+
+// encodeEntry encodes a value and sets it in a dictionary
+func (e *encoder) encodeEntry(dict *ast.DictNode, key string, v reflect.Value, name bool) error {
+	e.path = append(e.path, ast.PathStep{Key: key})
+	value, err := e.encode(v, name)
+	e.path = e.path[:len(e.path)-1]
+
+	if err != nil {
+		return err
+	}
+
+	dict.Set(key, value)
+	return nil
+}
+
+// bytesOf returns the bytes of a byte slice or array
+func bytesOf(v reflect.Value) []byte {
+	data := make([]byte, v.Len())
+	reflect.Copy(reflect.ValueOf(data), v)
+	return data
+}
+
+// encodeText encodes text for a string. ASCII text is stored as it is, and
+// anything else as UTF-16BE with a byte order mark.
+func encodeText(text string) []byte {
+	ascii := true
+
+	for i := 0; i < len(text); i++ {
+		if text[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+
+	if ascii {
+		return []byte(text)
+	}
+
+	data := []byte{0xfe, 0xff}
+
+	for _, unit := range utf16.Encode([]rune(text)) {
+		data = append(data, byte(unit>>8), byte(unit))
+	}
+
+	return data
+}
@@ -0,0 +1,291 @@
+package marshal_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/marshal"
+	"github.com/rgracey/pdf/pkg/parser"
+	"github.com/rgracey/pdf/pkg/tokeniser"
+)
+
+const document = `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R /Lang (en-GB) >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R] /Count 1 >>
+endobj
+3 0 obj
+<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792.5] /Resources << /Font << /F1 4 0 R >> >> >>
+endobj
+4 0 obj
+<< /Type /Font /Subtype (Type1) >>
+endobj
+trailer
+<< /Size 5 /Root 1 0 R >>
+`
+
+type catalog struct {
+	Type  string `pdf:"Type,name"`
+	Pages pages  `pdf:"Pages"`
+	Lang  string `pdf:"Lang,optional"`
+	Title string `pdf:"Title,optional"`
+}
+
+type pages struct {
+	Kids  []page `pdf:"Kids"`
+	Count int    `pdf:"Count"`
+}
+
+type page struct {
+	Parent    *ast.ObjectRefNode `pdf:"Parent"`
+	MediaBox  [4]float64         `pdf:"MediaBox"`
+	Resources struct {
+		Font map[string]ast.PdfNode `pdf:"Font,optional"`
+	} `pdf:"Resources"`
+}
+
+type font struct {
+	Type    marshal.Name
+	Subtype string `pdf:"Subtype,name"`
+}
+
+func parse(t *testing.T, input string) *ast.RootNode {
+	t.Helper()
+
+	root, err := parser.NewParser(tokeniser.NewTokeniser(strings.NewReader(input))).Parse()
+
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	return root.(*ast.RootNode)
+}
+
+func TestUnmarshal(t *testing.T) {
+	root := parse(t, document)
+	r := ast.NewRootResolver(root)
+
+	var c catalog
+
+	if err := marshal.Unmarshal(ast.NewObjectRefNode(1, 0), &c, r); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if c.Type != "Catalog" || c.Lang != "en-GB" || c.Title != "" {
+		t.Errorf("Unexpected catalog %+v", c)
+	}
+
+	if c.Pages.Count != 1 || len(c.Pages.Kids) != 1 {
+		t.Fatalf("Unexpected pages %+v", c.Pages)
+	}
+
+	page := c.Pages.Kids[0]
+
+	if page.Parent.Id() != 2 {
+		t.Errorf("Expected the parent to be left as a reference, got %v", page.Parent)
+	}
+
+	if page.MediaBox != [4]float64{0, 0, 612, 792.5} {
+		t.Errorf("Unexpected media box %v", page.MediaBox)
+	}
+
+	if ref, ok := page.Resources.Font["F1"].(*ast.ObjectRefNode); !ok || ref.Id() != 4 {
+		t.Errorf("Expected the font to be left as a reference, got %v", page.Resources.Font["F1"])
+	}
+}
+
+func TestUnmarshal_ErrorPaths(t *testing.T) {
+	root := parse(t, document)
+	r := ast.NewRootResolver(root)
+
+	var f font
+	err := marshal.Unmarshal(ast.NewObjectRefNode(4, 0), &f, r)
+
+	var marshalErr *marshal.Error
+
+	if !errors.As(err, &marshalErr) || !errors.Is(err, ast.ErrWrongType) {
+		t.Fatalf("Expected a wrong type error, got %v", err)
+	}
+
+	if marshalErr.Path.String() != "/Subtype" {
+		t.Errorf("Expected the error at /Subtype, got %q", marshalErr.Path)
+	}
+
+	var c struct {
+		Pages struct {
+			Kids []struct {
+				Resources struct {
+					Font map[string]font
+				}
+			}
+		}
+	}
+
+	err = marshal.Unmarshal(root.GetTrailer().Children()[0].(*ast.DictNode).Get("Root"), &c, r)
+
+	if err == nil || err.Error() != "/Pages/Kids[0]/Resources/Font/F1/Subtype: wrong node type: expected NAME, got STRING" {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	var missing struct {
+		Title string
+	}
+
+	err = marshal.Unmarshal(ast.NewDictNode(), &missing, nil)
+
+	if !errors.Is(err, marshal.ErrMissingKey) || !strings.HasPrefix(err.Error(), "/Title:") {
+		t.Errorf("Expected a missing key error, got %v", err)
+	}
+}
+
+func TestUnmarshal_References(t *testing.T) {
+	root := parse(t, document)
+
+	var c catalog
+
+	if err := marshal.Unmarshal(root.Object(1), &c, nil); !errors.Is(err, ast.ErrWrongType) {
+		t.Errorf("Expected an indirect object not to be a dictionary, got %v", err)
+	}
+
+	if err := marshal.Unmarshal(root.Object(1).Children()[0], &c, nil); !errors.Is(err, ast.ErrUnresolvedReference) {
+		t.Errorf("Expected references not to resolve without a resolver, got %v", err)
+	}
+
+	// A page's parent refers back to the pages it's in, so can only be held as
+	// a reference
+	var kids struct {
+		Kids []struct {
+			Parent *ast.ObjectRefNode
+		}
+	}
+
+	if err := marshal.Unmarshal(ast.NewObjectRefNode(2, 0), &kids, ast.NewRootResolver(root)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var cyclic struct {
+		Kids []struct {
+			Parent struct {
+				Count int
+			}
+		}
+	}
+
+	err := marshal.Unmarshal(ast.NewObjectRefNode(2, 0), &cyclic, ast.NewRootResolver(root))
+
+	if !errors.Is(err, ast.ErrCircularReference) || !strings.HasPrefix(err.Error(), "/Kids[0]/Parent:") {
+		t.Errorf("Expected a circular reference error, got %v", err)
+	}
+}
+
+func TestUnmarshal_Values(t *testing.T) {
+	var small int8
+
+	if err := marshal.Unmarshal(ast.NewIntegerNode(300), &small, nil); !errors.Is(err, marshal.ErrOutOfRange) {
+		t.Errorf("Expected an out of range error, got %v", err)
+	}
+
+	var text string
+
+	if err := marshal.Unmarshal(ast.NewStringNode([]byte{0xfe, 0xff, 0x00, 0xe9}), &text, nil); err != nil || text != "é" {
+		t.Errorf("Expected é, got %q (%v)", text, err)
+	}
+
+	var raw []byte
+
+	if err := marshal.Unmarshal(ast.NewHexStringNode([]byte{1, 2}), &raw, nil); err != nil || !reflect.DeepEqual(raw, []byte{1, 2}) {
+		t.Errorf("Expected raw bytes, got %v (%v)", raw, err)
+	}
+
+	var names []marshal.Name
+	array := ast.NewArrayNode()
+	array.AddChild(ast.NewNameNode("PDF"))
+	array.AddChild(ast.NewNameNode("Text"))
+
+	if err := marshal.Unmarshal(array, &names, nil); err != nil || !reflect.DeepEqual(names, []marshal.Name{"PDF", "Text"}) {
+		t.Errorf("Expected names, got %v (%v)", names, err)
+	}
+
+	if err := marshal.Unmarshal(array, names, nil); !errors.Is(err, marshal.ErrInvalidTarget) {
+		t.Errorf("Expected an invalid target error, got %v", err)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	type info struct {
+		Title    string
+		Author   string  `pdf:"Author,optional"`
+		Trapped  string  `pdf:"Trapped,name"`
+		Version  float64 `pdf:"Version,optional"`
+		Pages    []int   `pdf:"Pages,optional"`
+		Parent   *ast.ObjectRefNode
+		Filters  []marshal.Name
+		Internal string `pdf:"-"`
+	}
+
+	node, err := marshal.Marshal(info{
+		Title:    "Café",
+		Trapped:  "False",
+		Pages:    []int{1, 2},
+		Parent:   ast.NewObjectRefNode(7, 0),
+		Filters:  []marshal.Name{"FlateDecode"},
+		Internal: "secret",
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	dict := node.(*ast.DictNode)
+
+	if !reflect.DeepEqual(dict.Keys(), []string{"Title", "Trapped", "Pages", "Parent", "Filters"}) {
+		t.Errorf("Unexpected keys %v", dict.Keys())
+	}
+
+	if title, _ := ast.Text(dict.Get("Title")); title != "Café" {
+		t.Errorf("Expected the title to round trip, got %q", title)
+	}
+
+	if name, _ := ast.Name(dict.Get("Trapped")); name != "False" {
+		t.Errorf("Expected /Trapped to be a name, got %v", dict.Get("Trapped"))
+	}
+
+	if ref, ok := dict.Get("Parent").(*ast.ObjectRefNode); !ok || ref.Id() != 7 {
+		t.Errorf("Expected the reference as it was, got %v", dict.Get("Parent"))
+	}
+
+	if name, _ := ast.Name(dict.Get("Filters").Children()[0]); name != "FlateDecode" {
+		t.Errorf("Expected an array of names, got %v", dict.Get("Filters"))
+	}
+
+	var decoded info
+
+	if err := marshal.Unmarshal(node, &decoded, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if decoded.Title != "Café" || decoded.Trapped != "False" || len(decoded.Pages) != 2 {
+		t.Errorf("Expected the value to round trip, got %+v", decoded)
+	}
+}
+
+func TestMarshal_Unsupported(t *testing.T) {
+	_, err := marshal.Marshal(struct {
+		Values map[string]chan int
+	}{map[string]chan int{"A": nil}})
+
+	var marshalErr *marshal.Error
+
+	if !errors.As(err, &marshalErr) || !errors.Is(err, marshal.ErrUnsupportedType) {
+		t.Fatalf("Expected an unsupported type error, got %v", err)
+	}
+
+	if marshalErr.Path.String() != "/Values/A" {
+		t.Errorf("Expected the error at /Values/A, got %q", marshalErr.Path)
+	}
+}
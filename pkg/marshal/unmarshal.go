@@ -0,0 +1,320 @@
+package marshal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/rgracey/pdf/pkg/ast"
+)
+
+var ErrInvalidTarget = errors.New("target must be a non-nil pointer")
+
+var objectType = reflect.TypeOf((*ast.IndirectObjectNode)(nil))
+
+// Unmarshal stores a PDF object in the Go value v points to. Dictionary keys
+// are matched to struct fields by their tags, and a missing key is an error
+// unless the field is optional. Null is treated as a missing value.
+//
+// References are resolved through the resolver, so that a struct field can
+// hold the object a reference points to. Without a resolver, references can
+// only be stored in fields of type *ast.ObjectRefNode or ast.PdfNode, which
+// hold them as they are. A field of type *ast.IndirectObjectNode holds the
+// object a reference points to, e.g. to get at a stream.
+//
+// Errors are of type *Error, giving the path of the value that couldn't be
+// stored.
+func Unmarshal(node ast.PdfNode, v interface{}, r *ast.Resolver) error {
+	target := reflect.ValueOf(v)
+
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return &Error{ast.Path{}, ErrInvalidTarget}
+	}
+
+	if node == nil {
+		return &Error{ast.Path{}, fmt.Errorf("%w: got nothing", ast.ErrWrongType)}
+	}
+
+	d := &decoder{resolver: r, resolving: map[int64]bool{}}
+	return d.decode(node, target.Elem(), false)
+}
+
+// decoder decodes PDF objects, keeping track of the path to the current one
+type decoder struct {
+	resolver  *ast.Resolver
+	path      ast.Path
+	resolving map[int64]bool // Objects being decoded, to catch cycles
+}
+
+func (d *decoder) fail(err error) error {
+	return &Error{append(ast.Path{}, d.path...), err}
+}
+
+func (d *decoder) decode(node ast.PdfNode, v reflect.Value, name bool) error {
+	// Nodes are stored as they are if the field can hold them
+	nodeField := v.Kind() == reflect.Interface || v.Type().Implements(nodeType)
+
+	if nodeField && reflect.TypeOf(node).AssignableTo(v.Type()) {
+		v.Set(reflect.ValueOf(node))
+		return nil
+	}
+
+	if ref, ok := node.(*ast.ObjectRefNode); ok {
+		return d.decodeRef(ref, v, name)
+	}
+
+	if node.Type() == ast.NULL {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if nodeField {
+		return d.fail(fmt.Errorf("%w: expected %s, got %s", ast.ErrWrongType, v.Type(), node.Type()))
+	}
+
+	if v.Type() == nameType {
+		name = true
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return d.decode(node, v.Elem(), name)
+
+	case reflect.Bool:
+		value, err := ast.Bool(node)
+
+		if err != nil {
+			return d.fail(err)
+		}
+
+		v.SetBool(value)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, err := ast.Int(node)
+
+		if err != nil {
+			return d.fail(err)
+		}
+
+		if v.OverflowInt(value) {
+			return d.fail(fmt.Errorf("%w: %d for %s", ErrOutOfRange, value, v.Type()))
+		}
+
+		v.SetInt(value)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		value, err := ast.Int(node)
+
+		if err != nil {
+			return d.fail(err)
+		}
+
+		if value < 0 || v.OverflowUint(uint64(value)) {
+			return d.fail(fmt.Errorf("%w: %d for %s", ErrOutOfRange, value, v.Type()))
+		}
+
+		v.SetUint(uint64(value))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		value, err := ast.Number(node)
+
+		if err != nil {
+			return d.fail(err)
+		}
+
+		if v.Kind() == reflect.Float32 && math.Abs(value) > math.MaxFloat32 {
+			return d.fail(fmt.Errorf("%w: %v for %s", ErrOutOfRange, value, v.Type()))
+		}
+
+		v.SetFloat(value)
+		return nil
+
+	case reflect.String:
+		var value string
+		var err error
+
+		if name {
+			value, err = ast.Name(node)
+		} else {
+			value, err = ast.Text(node)
+		}
+
+		if err != nil {
+			return d.fail(err)
+		}
+
+		v.SetString(value)
+		return nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return d.decodeBytes(node, v)
+		}
+
+		return d.decodeArray(node, v, name)
+
+	case reflect.Map:
+		return d.decodeMap(node, v, name)
+
+	case reflect.Struct:
+		return d.decodeStruct(node, v)
+	}
+
+	return d.fail(fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type()))
+}
+
+// decodeRef decodes the object a reference points to
+func (d *decoder) decodeRef(ref *ast.ObjectRefNode, v reflect.Value, name bool) error {
+	if d.resolver == nil {
+		return d.fail(fmt.Errorf("%w: %d %d R: no resolver", ast.ErrUnresolvedReference, ref.Id(), ref.Gen()))
+	}
+
+	if d.resolving[ref.Id()] {
+		return d.fail(fmt.Errorf("%w: %d %d R", ast.ErrCircularReference, ref.Id(), ref.Gen()))
+	}
+
+	obj, err := d.resolver.Object(ref)
+
+	if err != nil {
+		return d.fail(err)
+	}
+
+	if v.Type() == objectType {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	resolved, err := d.resolver.Resolve(ref)
+
+	if err != nil {
+		return d.fail(err)
+	}
+
+	d.resolving[ref.Id()] = true
+	defer delete(d.resolving, ref.Id())
+
+	return d.decode(resolved, v, name)
+}
+
+// decodeBytes stores the raw bytes of a string
+func (d *decoder) decodeBytes(node ast.PdfNode, v reflect.Value) error {
+	str, ok := node.(*ast.StringNode)
+
+	if !ok {
+		return d.fail(fmt.Errorf("%w: expected %s, got %s", ast.ErrWrongType, ast.STRING, node.Type()))
+	}
+
+	data := str.Value().([]byte)
+
+	if v.Kind() == reflect.Array {
+		if len(data) != v.Len() {
+			return d.fail(fmt.Errorf("%w: %d bytes for %s", ErrOutOfRange, len(data), v.Type()))
+		}
+
+		reflect.Copy(v, reflect.ValueOf(data))
+		return nil
+	}
+
+	v.SetBytes(append([]byte{}, data...))
+	return nil
+}
+
+func (d *decoder) decodeArray(node ast.PdfNode, v reflect.Value, name bool) error {
+	array, err := ast.Array(node)
+
+	if err != nil {
+		return d.fail(err)
+	}
+
+	elements := array.Children()
+
+	if v.Kind() == reflect.Array {
+		if len(elements) != v.Len() {
+			return d.fail(fmt.Errorf("%w: %d elements for %s", ErrOutOfRange, len(elements), v.Type()))
+		}
+	} else {
+		v.Set(reflect.MakeSlice(v.Type(), len(elements), len(elements)))
+	}
+
+	for i, element := range elements {
+		d.path = append(d.path, ast.PathStep{Index: i})
+		err := d.decode(element, v.Index(i), name)
+		d.path = d.path[:len(d.path)-1]
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *decoder) decodeMap(node ast.PdfNode, v reflect.Value, name bool) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return d.fail(fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type()))
+	}
+
+	dict, err := ast.Dict(node)
+
+	if err != nil {
+		return d.fail(err)
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+
+	for _, entry := range dict.Entries() {
+		value := reflect.New(v.Type().Elem()).Elem()
+
+		d.path = append(d.path, ast.PathStep{Key: entry.Key})
+		err := d.decode(entry.Value, value, name)
+		d.path = d.path[:len(d.path)-1]
+
+		if err != nil {
+			return err
+		}
+
+		v.SetMapIndex(reflect.ValueOf(entry.Key).Convert(v.Type().Key()), value)
+	}
+
+	return nil
+}
+
+func (d *decoder) decodeStruct(node ast.PdfNode, v reflect.Value) error {
+	dict, err := ast.Dict(node)
+
+	if err != nil {
+		return d.fail(err)
+	}
+
+	for _, f := range structFields(v.Type()) {
+		value := dict.Get(f.key)
+
+		d.path = append(d.path, ast.PathStep{Key: f.key})
+
+		if value == nil || value.Type() == ast.NULL {
+			if !f.optional {
+				err = d.fail(ErrMissingKey)
+			}
+		} else {
+			err = d.decode(value, v.FieldByIndex(f.index), f.name)
+		}
+
+		d.path = d.path[:len(d.path)-1]
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/token"
+)
+
+var (
+	ErrUnexpectedKeyword = errors.New("unexpected keyword")
+	ErrUnexpectedEnd     = errors.New("unexpected end")
+	ErrUnexpectedEOF     = errors.New("unexpected end of input")
+	ErrUnexpectedToken   = errors.New("unexpected token")
+	ErrInvalidXRef       = errors.New("invalid cross-reference entry")
+)
+
+// ParseError describes why and where the parser failed to parse its input
+type ParseError struct {
+	Offset int64       // Byte offset of the offending token in the input
+	Token  token.Token // The offending token
+	Stack  []ast.Type  // Types of the nodes being parsed, outermost first
+	Err    error       // The underlying error
+}
+
+func (e *ParseError) Error() string {
+	stack := []string{}
+
+	for _, nodeType := range e.Stack {
+		stack = append(stack, nodeType.String())
+	}
+
+	return fmt.Sprintf(
+		"parse error at offset %d (%s) in %s: %v",
+		e.Offset,
+		e.Token,
+		strings.Join(stack, " > "),
+		e.Err,
+	)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -8,12 +8,19 @@ import (
 	"github.com/rgracey/pdf/pkg/tokeniser"
 )
 
+// ObjectLookup finds an indirect object that the parser hasn't parsed itself,
+// for example to find the length of a stream
+type ObjectLookup func(id int64, gen int64) (*ast.IndirectObjectNode, error)
+
 // Parser takes tokens and builds another representation of the PDF
 type Parser struct {
 	tokeniser   tokeniser.Tokeniser
 	ast         ast.PdfNode
 	current     ast.PdfNode
 	parentStack []ast.PdfNode
+	objects     map[int64]*ast.IndirectObjectNode // Objects parsed so far
+	previous    [2]token.Token                    // The last 2 tokens read
+	lookup      ObjectLookup                      // Finds objects not yet parsed
 }
 
 func NewParser(tokeniser tokeniser.Tokeniser) *Parser {
@@ -23,20 +30,60 @@ func NewParser(tokeniser tokeniser.Tokeniser) *Parser {
 		tokeniser: tokeniser,
 		ast:       root,
 		current:   root,
+		objects:   make(map[int64]*ast.IndirectObjectNode),
 	}
 }
 
-// Parse reads tokens from the tokeniser and builds an AST
-func (p *Parser) Parse() ast.PdfNode {
+// SetObjectLookup sets the function used to find objects that haven't been
+// parsed (yet) by the parser
+func (p *Parser) SetObjectLookup(lookup ObjectLookup) {
+	p.lookup = lookup
+}
+
+// Parse reads tokens from the tokeniser and builds an AST. If the input is
+// malformed, a *ParseError is returned along with the partially built AST.
+func (p *Parser) Parse() (ast.PdfNode, error) {
+	for {
+		node, err := p.Next()
+
+		if err != nil || node == nil {
+			return p.ast, err
+		}
+	}
+}
+
+// Next parses up to the end of the next top level node (an indirect object,
+// cross-reference table or trailer) and returns it. The node is also added to
+// the AST. Once the input is exhausted nil is returned. If the input is
+// malformed, a *ParseError is returned.
+func (p *Parser) Next() (ast.PdfNode, error) {
 	for {
 		tok, err := p.tokeniser.NextToken()
 
 		if err != nil {
-			panic(err)
+			return nil, p.error(tok, err)
 		}
 
+		previous := p.previous
+		p.previous = [2]token.Token{previous[1], tok}
+
 		if tok.Type == token.EOF {
-			break
+			// Anything other than a trailer (which may be missing its %EOF
+			// marker) left open means the input was truncated
+			for _, node := range append(p.parentStack, p.current) {
+				switch node.Type() {
+				case ast.ROOT, ast.TRAILER:
+				default:
+					return nil, p.error(tok, ErrUnexpectedEOF)
+				}
+			}
+
+			if trailer := p.current; trailer.Type() == ast.TRAILER {
+				p.pop(ast.TRAILER)
+				return trailer, nil
+			}
+
+			return nil, nil
 		}
 
 		switch tok.Type {
@@ -47,14 +94,14 @@ func (p *Parser) Parse() ast.PdfNode {
 				// If they're both integers, then we have an indirect object or
 				// a reference to an indirect object
 				if len(p.current.Children()) < 2 {
-					panic("Unexpected obj")
+					return nil, p.error(tok, ErrUnexpectedKeyword)
 				}
 
 				gen := p.current.Children()[len(p.current.Children())-1]
 				id := p.current.Children()[len(p.current.Children())-2]
 
 				if gen.Type() != ast.INTEGER || id.Type() != ast.INTEGER {
-					panic("Unexpected obj")
+					return nil, p.error(tok, ErrUnexpectedKeyword)
 				}
 
 				p.current.RemoveChild(len(p.current.Children()) - 1)
@@ -66,6 +113,9 @@ func (p *Parser) Parse() ast.PdfNode {
 						id.Value().(int64),
 						gen.Value().(int64),
 					)
+					// The object starts at its object number
+					obj.SetOffset(previous[0].Offset)
+					p.objects[obj.Id()] = obj
 					p.push(obj)
 
 				case "R":
@@ -77,25 +127,45 @@ func (p *Parser) Parse() ast.PdfNode {
 				}
 
 			case "endobj":
-				p.pop()
+				obj := p.current
+
+				if err := p.pop(ast.INDIRECT_OBJECT); err != nil {
+					return nil, p.error(tok, err)
+				}
+
+				if p.current == p.ast {
+					return obj, nil
+				}
+
+			case "stream":
+				tok, err := p.tokeniser.ReadStream(p.streamLength())
+
+				if err != nil {
+					return nil, p.error(tok, err)
+				}
+
+				p.current.AddChild(ast.NewStreamNode(tok.Value.([]byte)))
 
 			case "endstream":
 				// Nothing to do here for now
 
 			case "xref":
-				// We don't actually care for the xref table
-				// But to keep the parsing clean, we'll push
-				// a node onto the parent stack so we can store
-				// all the xref entries somewhere
-				p.push(ast.NewXRefsNode())
+				xrefs := ast.NewXRefsNode()
+
+				if tok, err := p.parseXRefs(xrefs); err != nil {
+					return nil, p.error(tok, err)
+				}
+
+				p.current.AddChild(xrefs)
+
+				if p.current == p.ast {
+					return xrefs, nil
+				}
 
 			case "startxref":
 				// Nothing to do here for now
 
 			case "trailer":
-				if p.current.Type() == ast.XREFS {
-					p.pop()
-				}
 				p.push(ast.NewTrailerNode())
 			}
 
@@ -106,9 +176,13 @@ func (p *Parser) Parse() ast.PdfNode {
 			}
 
 			// The trailer is terminated by the %EOF comment
-			if tok.Value.(string) == "%EOF" &&
-				p.current.Type() == ast.TRAILER {
-				p.pop()
+			if trailer := p.current; tok.Value.(string) == "%EOF" &&
+				trailer.Type() == ast.TRAILER {
+				p.pop(ast.TRAILER)
+
+				if p.current == p.ast {
+					return trailer, nil
+				}
 			}
 
 		case token.NAME:
@@ -120,34 +194,48 @@ func (p *Parser) Parse() ast.PdfNode {
 		case token.DELIMITER:
 			// TODO - Handle delimiters?
 
-		// TODO - Handle null otherwise dictionaries could have trouble
-		// (uneven number of children)
-		// Could potentially just return null as default?
-		// case token.NULL:
+		case token.NULL:
+			p.current.AddChild(ast.NewNullNode())
 
 		case token.DICT_START:
 			p.push(ast.NewDictNode())
 
 		case token.DICT_END:
-			p.pop()
+			// A key without a value is taken to have a null value
+			if dict, ok := p.current.(*ast.DictNode); ok && len(dict.Children())%2 != 0 {
+				dict.AddChild(ast.NewNullNode())
+			}
+
+			if err := p.pop(ast.DICT); err != nil {
+				return nil, p.error(tok, err)
+			}
 
 		case token.ARRAY_START:
 			p.push(ast.NewArrayNode())
 
 		case token.ARRAY_END:
-			p.pop()
+			if err := p.pop(ast.ARRAY); err != nil {
+				return nil, p.error(tok, err)
+			}
 
 		case token.FUNCTION_START:
 			p.push(ast.NewFunctionNode())
 
 		case token.FUNCTION_END:
-			p.pop()
+			if err := p.pop(ast.FUNCTION); err != nil {
+				return nil, p.error(tok, err)
+			}
 
 		case token.STREAM:
-			p.current.AddChild(ast.NewStreamNode(tok.Value.(string)))
+			p.current.AddChild(ast.NewStreamNode(tok.Value.([]byte)))
 
 		case token.STRING_LITERAL:
-			p.current.AddChild(ast.NewStringNode(tok.Value.(string)))
+			p.current.AddChild(ast.NewStringNode(tok.Value.([]byte)))
+
+		case token.HEX_STRING:
+			str := ast.NewHexStringNode(tok.Value.([]byte))
+			str.SetHexDigits(tok.Raw)
+			p.current.AddChild(str)
 
 		case token.NUMBER_FLOAT:
 			p.current.AddChild(ast.NewFloatNode(tok.Value.(float64)))
@@ -155,10 +243,145 @@ func (p *Parser) Parse() ast.PdfNode {
 		case token.NUMBER_INTEGER:
 			p.current.AddChild(ast.NewIntegerNode(tok.Value.(int64)))
 		}
+	}
+}
+
+// parseXRefs parses the subsections of a cross-reference table following the
+// xref keyword, adding each entry to the given node. On error the offending
+// token is returned.
+func (p *Parser) parseXRefs(xrefs *ast.XRefsNode) (token.Token, error) {
+	for {
+		tok, err := p.tokeniser.NextToken()
+
+		if err != nil {
+			return tok, err
+		}
+
+		// Subsections continue until something other than a subsection
+		// header (usually the trailer keyword)
+		if tok.Type != token.NUMBER_INTEGER {
+			p.tokeniser.UnreadToken()
+			return tok, nil
+		}
+
+		start := tok.Value.(int64)
+		tok, err = p.expect(token.NUMBER_INTEGER)
+
+		if err != nil {
+			return tok, err
+		}
+
+		count := tok.Value.(int64)
+
+		for i := int64(0); i < count; i++ {
+			offset, err := p.expect(token.NUMBER_INTEGER)
+
+			if err != nil {
+				return offset, err
+			}
+
+			gen, err := p.expect(token.NUMBER_INTEGER)
+
+			if err != nil {
+				return gen, err
+			}
+
+			entryType, err := p.expect(token.KEYWORD)
+
+			if err != nil {
+				return entryType, err
+			}
+
+			entry := ast.XRefEntry{
+				Id:     start + i,
+				Offset: offset.Value.(int64),
+				Gen:    gen.Value.(int64),
+			}
+
+			switch entryType.Value {
+			case "n":
+				entry.Type = ast.XREF_IN_USE
+			case "f":
+				entry.Type = ast.XREF_FREE
+			default:
+				return entryType, ErrInvalidXRef
+			}
+
+			xrefs.AddEntry(entry)
+		}
+	}
+}
+
+// expect reads the next token, returning an error if it isn't of the expected
+// type
+func (p *Parser) expect(tokenType token.Type) (token.Token, error) {
+	tok, err := p.tokeniser.NextToken()
+
+	if err != nil {
+		return tok, err
+	}
+
+	if tok.Type != tokenType {
+		return tok, ErrUnexpectedToken
+	}
+
+	return tok, nil
+}
+
+// streamLength returns the length of the stream about to be read, from the
+// /Length entry of the stream dictionary. This may be a reference to an object
+// that has already been parsed. If the length isn't known, -1 is returned.
+func (p *Parser) streamLength() int64 {
+	children := p.current.Children()
+
+	if len(children) == 0 {
+		return -1
+	}
+
+	dict, ok := children[len(children)-1].(*ast.DictNode)
+
+	if !ok {
+		return -1
+	}
+
+	length := dict.Get("Length")
+
+	if ref, ok := length.(*ast.ObjectRefNode); ok {
+		obj := p.object(ref.Id(), ref.Gen())
+
+		if obj == nil || len(obj.Children()) != 1 {
+			return -1
+		}
+
+		length = obj.Children()[0]
+	}
+
+	if length == nil || length.Type() != ast.INTEGER {
+		return -1
+	}
+
+	return length.Value().(int64)
+}
+
+// object returns the indirect object with the given id and generation, either
+// from those parsed so far or using the object lookup. If the object can't be
+// found nil is returned.
+func (p *Parser) object(id int64, gen int64) *ast.IndirectObjectNode {
+	if obj, ok := p.objects[id]; ok && obj.Gen() == gen {
+		return obj
+	}
 
+	if p.lookup == nil {
+		return nil
 	}
 
-	return p.ast
+	obj, err := p.lookup(id, gen)
+
+	if err != nil {
+		return nil
+	}
+
+	return obj
 }
 
 // push pushes a node onto the parent stack and sets it as the current node
@@ -168,11 +391,30 @@ func (p *Parser) push(node ast.PdfNode) {
 	p.current = node
 }
 
-// pop pops a node off the parent stack and sets it as the current node
-func (p *Parser) pop() {
-	if len(p.parentStack) == 0 {
-		panic("Unexpected end")
+// pop pops a node off the parent stack and sets it as the current node. The
+// current node must be of the expected type.
+func (p *Parser) pop(expected ast.Type) error {
+	if len(p.parentStack) == 0 || p.current.Type() != expected {
+		return ErrUnexpectedEnd
 	}
+
 	p.current = p.parentStack[len(p.parentStack)-1]
 	p.parentStack = p.parentStack[:len(p.parentStack)-1]
+	return nil
+}
+
+// error wraps err in a ParseError describing where parsing failed
+func (p *Parser) error(tok token.Token, err error) *ParseError {
+	stack := []ast.Type{}
+
+	for _, parent := range p.parentStack {
+		stack = append(stack, parent.Type())
+	}
+
+	return &ParseError{
+		Offset: tok.Offset,
+		Token:  tok,
+		Stack:  append(stack, p.current.Type()),
+		Err:    err,
+	}
 }
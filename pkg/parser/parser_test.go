@@ -1,6 +1,8 @@
 package parser_test
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/rgracey/pdf/pkg/ast"
@@ -16,7 +18,11 @@ func TestParser_ParsesVersion(t *testing.T) {
 	}
 
 	parser := parser.NewParser(tokeniser)
-	root := parser.Parse()
+	root, err := parser.Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
 
 	expectNode(t, root, ast.ROOT, "PDF-1.7")
 	expectChildren(t, root, []ast.PdfNode{})
@@ -37,7 +43,11 @@ func TestParser_ParsesIndirectObject(t *testing.T) {
 	}
 
 	parser := parser.NewParser(tokeniser)
-	root := parser.Parse()
+	root, err := parser.Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
 
 	expectNode(t, root, ast.ROOT, nil)
 	expectChildren(t, root, []ast.PdfNode{
@@ -60,6 +70,334 @@ func TestParser_ParsesIndirectObject(t *testing.T) {
 	})
 }
 
+func TestParser_ParsesHexStrings(t *testing.T) {
+	tokeniser := &mockTokeniser{
+		tokens: []token.Token{
+			{Type: token.HEX_STRING, Value: []byte("Hello")},
+			{Type: token.STRING_LITERAL, Value: []byte("Hello")},
+		},
+	}
+
+	root, err := parser.NewParser(tokeniser).Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectChildren(t, root, []ast.PdfNode{
+		ast.NewHexStringNode([]byte("Hello")),
+		ast.NewStringNode([]byte("Hello")),
+	})
+
+	if !root.Children()[0].(*ast.StringNode).IsHex() {
+		t.Errorf("Expected first string to be hex")
+	}
+
+	if root.Children()[1].(*ast.StringNode).IsHex() {
+		t.Errorf("Expected second string to be literal")
+	}
+}
+
+func TestParser_ParsesNullInDict(t *testing.T) {
+	tokeniser := &mockTokeniser{
+		tokens: []token.Token{
+			{Type: token.DICT_START},
+			{Type: token.NAME, Value: "A"},
+			{Type: token.NULL},
+			{Type: token.NAME, Value: "B"},
+			{Type: token.NUMBER_INTEGER, Value: int64(1)},
+			{Type: token.DICT_END},
+		},
+	}
+
+	root, err := parser.NewParser(tokeniser).Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	dict := root.Children()[0].(*ast.DictNode)
+
+	expectChildren(t, dict, []ast.PdfNode{
+		ast.NewNameNode("A"),
+		ast.NewNullNode(),
+		ast.NewNameNode("B"),
+		ast.NewIntegerNode(1),
+	})
+
+	expectNode(t, dict.Get("A"), ast.NULL, nil)
+	expectNode(t, dict.Get("B"), ast.INTEGER, int64(1))
+}
+
+func TestParser_ParsesNullInArray(t *testing.T) {
+	tokeniser := &mockTokeniser{
+		tokens: []token.Token{
+			{Type: token.ARRAY_START},
+			{Type: token.NULL},
+			{Type: token.NUMBER_INTEGER, Value: int64(1)},
+			{Type: token.ARRAY_END},
+		},
+	}
+
+	root, err := parser.NewParser(tokeniser).Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectChildren(t, root.Children()[0], []ast.PdfNode{
+		ast.NewNullNode(),
+		ast.NewIntegerNode(1),
+	})
+}
+
+func TestParser_ReadsStreamsUsingLength(t *testing.T) {
+	tokeniser := &mockTokeniser{
+		tokens: []token.Token{
+			{Type: token.NUMBER_INTEGER, Value: int64(2)},
+			{Type: token.NUMBER_INTEGER, Value: int64(0)},
+			{Type: token.KEYWORD, Value: "obj"},
+			{Type: token.NUMBER_INTEGER, Value: int64(5)},
+			{Type: token.KEYWORD, Value: "endobj"},
+
+			// Direct length
+			{Type: token.NUMBER_INTEGER, Value: int64(1)},
+			{Type: token.NUMBER_INTEGER, Value: int64(0)},
+			{Type: token.KEYWORD, Value: "obj"},
+			{Type: token.DICT_START},
+			{Type: token.NAME, Value: "Length"},
+			{Type: token.NUMBER_INTEGER, Value: int64(3)},
+			{Type: token.DICT_END},
+			{Type: token.KEYWORD, Value: "stream"},
+			{Type: token.STREAM, Value: []byte("abc")},
+			{Type: token.KEYWORD, Value: "endobj"},
+
+			// Indirect length, already parsed
+			{Type: token.NUMBER_INTEGER, Value: int64(3)},
+			{Type: token.NUMBER_INTEGER, Value: int64(0)},
+			{Type: token.KEYWORD, Value: "obj"},
+			{Type: token.DICT_START},
+			{Type: token.NAME, Value: "Length"},
+			{Type: token.NUMBER_INTEGER, Value: int64(2)},
+			{Type: token.NUMBER_INTEGER, Value: int64(0)},
+			{Type: token.KEYWORD, Value: "R"},
+			{Type: token.DICT_END},
+			{Type: token.KEYWORD, Value: "stream"},
+			{Type: token.STREAM, Value: []byte("abcde")},
+			{Type: token.KEYWORD, Value: "endobj"},
+
+			// Indirect length, not yet parsed
+			{Type: token.NUMBER_INTEGER, Value: int64(4)},
+			{Type: token.NUMBER_INTEGER, Value: int64(0)},
+			{Type: token.KEYWORD, Value: "obj"},
+			{Type: token.DICT_START},
+			{Type: token.NAME, Value: "Length"},
+			{Type: token.NUMBER_INTEGER, Value: int64(9)},
+			{Type: token.NUMBER_INTEGER, Value: int64(0)},
+			{Type: token.KEYWORD, Value: "R"},
+			{Type: token.DICT_END},
+			{Type: token.KEYWORD, Value: "stream"},
+			{Type: token.STREAM, Value: []byte("abcde")},
+			{Type: token.KEYWORD, Value: "endobj"},
+		},
+	}
+
+	root, err := parser.NewParser(tokeniser).Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedLengths := []int64{3, 5, -1}
+
+	if !reflect.DeepEqual(tokeniser.streamLengths, expectedLengths) {
+		t.Errorf("Expected stream lengths %v, got %v", expectedLengths, tokeniser.streamLengths)
+	}
+
+	expectChildren(t, root.Children()[1], []ast.PdfNode{
+		ast.NewDictNode(),
+		ast.NewStreamNode([]byte("abc")),
+	})
+}
+
+func TestParser_ParsesXRefs(t *testing.T) {
+	tokeniser := &mockTokeniser{
+		tokens: []token.Token{
+			{Type: token.KEYWORD, Value: "xref"},
+			{Type: token.NUMBER_INTEGER, Value: int64(0)},
+			{Type: token.NUMBER_INTEGER, Value: int64(2)},
+			{Type: token.NUMBER_INTEGER, Value: int64(3)},
+			{Type: token.NUMBER_INTEGER, Value: int64(65535)},
+			{Type: token.KEYWORD, Value: "f"},
+			{Type: token.NUMBER_INTEGER, Value: int64(17)},
+			{Type: token.NUMBER_INTEGER, Value: int64(0)},
+			{Type: token.KEYWORD, Value: "n"},
+			{Type: token.NUMBER_INTEGER, Value: int64(3)},
+			{Type: token.NUMBER_INTEGER, Value: int64(1)},
+			{Type: token.NUMBER_INTEGER, Value: int64(0)},
+			{Type: token.NUMBER_INTEGER, Value: int64(1)},
+			{Type: token.KEYWORD, Value: "f"},
+			{Type: token.KEYWORD, Value: "trailer"},
+		},
+	}
+
+	root, err := parser.NewParser(tokeniser).Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectChildren(t, root, []ast.PdfNode{
+		ast.NewXRefsNode(),
+		ast.NewTrailerNode(),
+	})
+
+	xrefs := root.Children()[0].(*ast.XRefsNode)
+
+	expected := []ast.XRefEntry{
+		{Id: 0, Offset: 3, Gen: 65535, Type: ast.XREF_FREE},
+		{Id: 1, Offset: 17, Gen: 0, Type: ast.XREF_IN_USE},
+		{Id: 3, Offset: 0, Gen: 1, Type: ast.XREF_FREE},
+	}
+
+	if !reflect.DeepEqual(xrefs.Entries(), expected) {
+		t.Errorf("Expected entries %v, got %v", expected, xrefs.Entries())
+	}
+
+	if entry, ok := xrefs.Entry(1); !ok || entry.Offset != 17 {
+		t.Errorf("Expected entry for object 1 at offset 17, got %v", entry)
+	}
+
+	if free := xrefs.FreeEntries(); len(free) != 2 {
+		t.Errorf("Expected 2 free entries, got %v", free)
+	}
+}
+
+func TestParser_ReturnsErrorForInvalidXRefs(t *testing.T) {
+	tokeniser := &mockTokeniser{
+		tokens: []token.Token{
+			{Type: token.KEYWORD, Value: "xref"},
+			{Type: token.NUMBER_INTEGER, Value: int64(0)},
+			{Type: token.NUMBER_INTEGER, Value: int64(1)},
+			{Type: token.NUMBER_INTEGER, Value: int64(0)},
+			{Type: token.NUMBER_INTEGER, Value: int64(65535)},
+			{Type: token.KEYWORD, Value: "x"},
+		},
+	}
+
+	_, err := parser.NewParser(tokeniser).Parse()
+
+	if !errors.Is(err, parser.ErrInvalidXRef) {
+		t.Errorf("Expected ErrInvalidXRef, got %v", err)
+	}
+}
+
+func TestParser_ReturnsErrorForUnexpectedObj(t *testing.T) {
+	tokeniser := &mockTokeniser{
+		tokens: []token.Token{
+			{Type: token.NAME, Value: "Type", Offset: 0},
+			{Type: token.KEYWORD, Value: "obj", Offset: 6},
+		},
+	}
+
+	_, err := parser.NewParser(tokeniser).Parse()
+
+	var parseErr *parser.ParseError
+
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+
+	if !errors.Is(err, parser.ErrUnexpectedKeyword) {
+		t.Errorf("Expected ErrUnexpectedKeyword, got %v", parseErr.Err)
+	}
+
+	if parseErr.Offset != 6 {
+		t.Errorf("Expected offset 6, got %v", parseErr.Offset)
+	}
+
+	if parseErr.Token.Value != "obj" {
+		t.Errorf("Expected offending token \"obj\", got %v", parseErr.Token)
+	}
+}
+
+func TestParser_ReturnsErrorForUnexpectedEnd(t *testing.T) {
+	tokeniser := &mockTokeniser{
+		tokens: []token.Token{
+			{Type: token.NUMBER_INTEGER, Value: int64(1)},
+			{Type: token.NUMBER_INTEGER, Value: int64(0)},
+			{Type: token.KEYWORD, Value: "obj"},
+			{Type: token.ARRAY_START},
+			{Type: token.DICT_END, Offset: 10},
+		},
+	}
+
+	_, err := parser.NewParser(tokeniser).Parse()
+
+	var parseErr *parser.ParseError
+
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+
+	if !errors.Is(err, parser.ErrUnexpectedEnd) {
+		t.Errorf("Expected ErrUnexpectedEnd, got %v", parseErr.Err)
+	}
+
+	expectedStack := []ast.Type{ast.ROOT, ast.INDIRECT_OBJECT, ast.ARRAY}
+
+	if len(parseErr.Stack) != len(expectedStack) {
+		t.Fatalf("Expected stack %v, got %v", expectedStack, parseErr.Stack)
+	}
+
+	for i, nodeType := range expectedStack {
+		if parseErr.Stack[i] != nodeType {
+			t.Errorf("Expected stack %v, got %v", expectedStack, parseErr.Stack)
+		}
+	}
+}
+
+func TestParser_ReturnsErrorForTruncatedInput(t *testing.T) {
+	tokeniser := &mockTokeniser{
+		tokens: []token.Token{
+			{Type: token.NUMBER_INTEGER, Value: int64(1)},
+			{Type: token.NUMBER_INTEGER, Value: int64(0)},
+			{Type: token.KEYWORD, Value: "obj"},
+			{Type: token.DICT_START},
+		},
+	}
+
+	_, err := parser.NewParser(tokeniser).Parse()
+
+	if !errors.Is(err, parser.ErrUnexpectedEOF) {
+		t.Errorf("Expected ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestParser_ReturnsTokeniserErrors(t *testing.T) {
+	tokErr := errors.New("bad token")
+	tokeniser := &mockTokeniser{
+		tokens: []token.Token{{Offset: 3}},
+		errs:   map[int]error{0: tokErr},
+	}
+
+	_, err := parser.NewParser(tokeniser).Parse()
+
+	var parseErr *parser.ParseError
+
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+
+	if !errors.Is(err, tokErr) {
+		t.Errorf("Expected tokeniser error, got %v", parseErr.Err)
+	}
+
+	if parseErr.Offset != 3 {
+		t.Errorf("Expected offset 3, got %v", parseErr.Offset)
+	}
+}
+
 // expectChildren checks that a node has the expected number and type of children
 func expectChildren(t *testing.T, node ast.PdfNode, expectedChildren []ast.PdfNode) {
 	if len(node.Children()) != len(expectedChildren) {
@@ -78,24 +416,28 @@ func expectNode(t *testing.T, node ast.PdfNode, expectedType ast.Type, expectedV
 		t.Errorf("Expected node type %v, got %v", expectedType, node.Type())
 	}
 
-	if expectedValue != nil && node.Value() != expectedValue {
+	if expectedValue != nil && !reflect.DeepEqual(node.Value(), expectedValue) {
 		t.Errorf("Expected node value %v, got %v", expectedValue, node.Value())
 	}
 }
 
 type mockTokeniser struct {
-	tokens  []token.Token
-	current int
+	tokens        []token.Token
+	errs          map[int]error // Errors to return alongside the token at an index
+	current       int
+	streamLengths []int64 // Lengths passed to ReadStream
 }
 
 func (t *mockTokeniser) NextToken() (token.Token, error) {
 	if t.current >= len(t.tokens) {
+		t.current++
 		return token.Token{}, nil
 	}
 
 	tok := t.tokens[t.current]
+	err := t.errs[t.current]
 	t.current++
-	return tok, nil
+	return tok, err
 }
 
 func (t *mockTokeniser) UnreadToken() {
@@ -105,3 +447,8 @@ func (t *mockTokeniser) UnreadToken() {
 
 	t.current--
 }
+
+func (t *mockTokeniser) ReadStream(length int64) (token.Token, error) {
+	t.streamLengths = append(t.streamLengths, length)
+	return t.NextToken()
+}
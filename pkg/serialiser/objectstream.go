@@ -0,0 +1,173 @@
+package serialiser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/filters"
+)
+
+// The most objects to pack into a single object stream
+const objectStreamSize = 100
+
+// canPack returns true if the object may be stored in an object stream. Streams,
+// objects with a non-zero generation and the encryption dictionary (given by
+// encrypt, or -1 if there isn't one) must be written directly.
+func canPack(obj *ast.IndirectObjectNode, encrypt int64) bool {
+	if obj.Gen() != 0 || obj.Id() == encrypt {
+		return false
+	}
+
+	for _, child := range obj.Children() {
+		if child.Type() == ast.STREAM {
+			return false
+		}
+	}
+
+	return true
+}
+
+// encryptId returns the object number of the encryption dictionary referred to
+// by the trailer dictionary, or -1 if there isn't one
+func encryptId(trailer *ast.DictNode) int64 {
+	if trailer == nil {
+		return -1
+	}
+
+	if ref, ok := trailer.Get("Encrypt").(*ast.ObjectRefNode); ok {
+		return ref.Id()
+	}
+
+	return -1
+}
+
+// writeObjectStreams packs the objects into object streams and writes them,
+// adding the cross-reference entries for the streams and the objects in them.
+// The streams are numbered from id, or after the highest numbered object if
+// that's higher.
+func (s *AstSerialiser) writeObjectStreams(
+	w *countingWriter,
+	objects []*ast.IndirectObjectNode,
+	id int64,
+	entries map[int64]ast.XRefEntry,
+) error {
+	if next := highestId(entries) + 1; next > id {
+		id = next
+	}
+
+	for _, obj := range objects {
+		if obj.Id() >= id {
+			id = obj.Id() + 1
+		}
+	}
+
+	streams, packed, err := s.createObjectStreams(objects, id)
+
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range packed {
+		entries[entry.Id] = entry
+	}
+
+	for _, stream := range streams {
+		entries[stream.Id()] = inUseEntry(stream, w.n)
+
+		if err := s.write(w, stream); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createObjectStreams packs the objects into object streams, numbered from the
+// given id onwards. The stream objects are returned along with the
+// cross-reference entries for the objects packed into them.
+func (s *AstSerialiser) createObjectStreams(
+	objects []*ast.IndirectObjectNode,
+	id int64,
+) ([]*ast.IndirectObjectNode, []ast.XRefEntry, error) {
+	streams := []*ast.IndirectObjectNode{}
+	entries := []ast.XRefEntry{}
+
+	for start := 0; start < len(objects); start += objectStreamSize {
+		end := start + objectStreamSize
+
+		if end > len(objects) {
+			end = len(objects)
+		}
+
+		stream, err := s.createObjectStream(objects[start:end], id)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i, obj := range objects[start:end] {
+			entries = append(entries, ast.XRefEntry{
+				Id:     obj.Id(),
+				Offset: id,
+				Gen:    int64(i),
+				Type:   ast.XREF_COMPRESSED,
+			})
+		}
+
+		streams = append(streams, stream)
+		id++
+	}
+
+	return streams, entries, nil
+}
+
+// createObjectStream creates an object stream with the given id holding the
+// given objects
+func (s *AstSerialiser) createObjectStream(
+	objects []*ast.IndirectObjectNode,
+	id int64,
+) (*ast.IndirectObjectNode, error) {
+	header := strings.Builder{}
+	body := strings.Builder{}
+
+	for _, obj := range objects {
+		header.WriteString(fmt.Sprintf("%d %d ", obj.Id(), body.Len()))
+
+		// Objects in an object stream are just their value, without the obj
+		// and endobj keywords
+		for _, child := range obj.Children() {
+			serialised, err := s.Serialise(child)
+
+			if err != nil {
+				return nil, err
+			}
+
+			body.WriteString(serialised)
+		}
+
+		body.WriteString("\n")
+	}
+
+	header.WriteString("\n")
+
+	compressed, err := filters.FlateEncode([]byte(header.String() + body.String()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	dict := ast.NewDictNode()
+
+	dict.Set("Type", ast.NewNameNode("ObjStm"))
+	dict.Set("N", ast.NewIntegerNode(int64(len(objects))))
+	dict.Set("First", ast.NewIntegerNode(int64(header.Len())))
+	dict.Set("Filter", ast.NewNameNode("FlateDecode"))
+	dict.Set("Length", ast.NewIntegerNode(int64(len(compressed))))
+
+	stream := ast.NewIndirectObjectNode(id, 0)
+	stream.AddChild(dict)
+	stream.AddChild(ast.NewStreamNode(compressed))
+
+	return stream, nil
+}
@@ -1,121 +1,171 @@
 package serialiser
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/rgracey/pdf/pkg/ast"
 )
 
+// ErrInvalidObjectNumber is returned for an indirect object numbered 0 or
+// below. Object 0 is reserved as the head of the list of free objects in the
+// cross-reference section.
+var ErrInvalidObjectNumber = errors.New("invalid object number")
+
 // Serialiser is an interface for serialising a PDF AST to some other format
 type Serialiser interface {
 	Serialise(node ast.PdfNode) (string, error)
+	SerialiseTo(w io.Writer, node ast.PdfNode) error
 }
 
-// AstSerialiser is a serialiser that serialises a PDF AST to a string
+// AstSerialiser is a serialiser that serialises a PDF AST to PDF syntax
 type AstSerialiser struct {
+	xrefStream    bool // Write a cross-reference stream instead of a table
+	objectStreams bool // Pack objects into object streams where possible
+}
+
+// Option configures an AstSerialiser
+type Option func(*AstSerialiser)
+
+// WithXRefStream makes the serialiser write a (PDF 1.5+) cross-reference
+// stream, rather than a cross-reference table and trailer
+func WithXRefStream() Option {
+	return func(s *AstSerialiser) {
+		s.xrefStream = true
+	}
 }
 
-func NewSerialiser() Serialiser {
-	return &AstSerialiser{}
+// WithObjectStreams makes the serialiser pack objects into (PDF 1.5+) object
+// streams where possible. As objects in object streams can only be referred to
+// from a cross-reference stream, this implies WithXRefStream.
+func WithObjectStreams() Option {
+	return func(s *AstSerialiser) {
+		s.xrefStream = true
+		s.objectStreams = true
+	}
+}
+
+func NewSerialiser(options ...Option) Serialiser {
+	s := &AstSerialiser{}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
 }
 
 // Serialise serialises a PDF AST to a string
 // This can be called on any node in the AST, but the serialised output may mot
 // make sense
 func (s *AstSerialiser) Serialise(node ast.PdfNode) (string, error) {
-	switch node.Type() {
-	case ast.ROOT:
-		sb := strings.Builder{}
-		sb.WriteString(fmt.Sprintf("%%%s\n", node.Value()))
-
-		indirectObjectOffsets := []int{}
-		trailer := ""
-
-		for _, child := range node.Children() {
-			switch child.Type() {
-			case ast.INDIRECT_OBJECT:
-				indirectObjectOffsets = append(indirectObjectOffsets, sb.Len())
+	sb := strings.Builder{}
 
-			case ast.TRAILER:
-				// Serialise the trailer now as we need to output it
-				// after the xref table
-				t, err := s.Serialise(child)
+	if err := s.SerialiseTo(&sb, node); err != nil {
+		return "", err
+	}
 
-				if err != nil {
-					return "", err
-				}
+	return sb.String(), nil
+}
 
-				trailer = t
-				continue
-			}
+// SerialiseTo serialises a PDF AST to a writer. The output is written as it is
+// generated rather than built up in memory, with the offsets in the
+// cross-reference section worked out from the number of bytes written.
+func (s *AstSerialiser) SerialiseTo(w io.Writer, node ast.PdfNode) error {
+	cw := newCountingWriter(w, 0)
 
-			serialised, _ := s.Serialise(child)
-			sb.WriteString(serialised)
-		}
+	if err := s.write(cw, node); err != nil {
+		return err
+	}
 
-		xrefTableStartOffset := sb.Len()
+	return cw.err
+}
 
-		return fmt.Sprintf(
-			"%s%s\n%s\nstartxref\n%d\n%%EOF",
-			sb.String(),
-			createXrefTable(indirectObjectOffsets),
-			trailer,
-			xrefTableStartOffset,
-		), nil
+// write writes a node and its children
+func (s *AstSerialiser) write(w *countingWriter, node ast.PdfNode) error {
+	switch node.Type() {
+	case ast.ROOT:
+		return s.writeDocument(w, node)
 
 	case ast.BOOLEAN:
 		switch node.Value().(bool) {
 		case true:
-			return "true", nil
+			w.WriteString("true")
 		case false:
-			return "false", nil
+			w.WriteString("false")
 		}
 
+	case ast.NULL:
+		w.WriteString("null")
+
 	case ast.FLOAT:
-		return fmt.Sprintf("%f", node.Value().(float64)), nil
+		w.Printf("%f", node.Value().(float64))
 
 	case ast.INTEGER:
-		return fmt.Sprintf("%d", node.Value().(int64)), nil
+		w.Printf("%d", node.Value().(int64))
 
 	case ast.NAME:
-		return fmt.Sprintf("/%s", node.Value().(string)), nil
+		w.Printf("/%s", node.Value().(string))
 
 	case ast.DICT:
-		sb := strings.Builder{}
+		w.WriteString("<<")
+
 		for _, child := range node.Children() {
-			serialised, _ := s.Serialise(child)
-			sb.WriteString(serialised + " ")
+			if err := s.write(w, child); err != nil {
+				return err
+			}
+
+			w.WriteString(" ")
 		}
 
-		return fmt.Sprintf("<<%s>>", sb.String()), nil
+		w.WriteString(">>")
 
 	case ast.STRING:
-		return fmt.Sprintf("(%s)", node.Value().(string)), nil
+		if str, ok := node.(*ast.StringNode); ok && str.IsHex() {
+			// Keep the digits as they were written (e.g. lowercase) if known
+			if digits := str.HexDigits(); digits != nil {
+				w.Printf("<%s>", digits)
+			} else {
+				w.Printf("<%X>", node.Value().([]byte))
+			}
+
+			break
+		}
+
+		w.Printf("(%s)", escapeString(node.Value().([]byte)))
 
 	case ast.FUNCTION:
-		sb := strings.Builder{}
+		w.WriteString("{ ")
+
 		for _, child := range node.Children() {
-			serialised, _ := s.Serialise(child)
-			sb.WriteString(serialised)
+			if err := s.write(w, child); err != nil {
+				return err
+			}
 		}
 
-		return fmt.Sprintf("{ %s }", sb.String()), nil
+		w.WriteString(" }")
 
 	case ast.ARRAY:
-		sb := strings.Builder{}
-		for _, child := range node.Children() {
-			serialised, _ := s.Serialise(child)
-			sb.WriteString(serialised + " ")
+		w.WriteString("[")
+
+		for i, child := range node.Children() {
+			if i > 0 {
+				w.WriteString(" ")
+			}
+
+			if err := s.write(w, child); err != nil {
+				return err
+			}
 		}
 
-		return fmt.Sprintf(
-			"[%s]",
-			strings.TrimRight(sb.String(), " "),
-		), nil
+		w.WriteString("]")
 
 	case ast.STREAM:
-		return fmt.Sprintf("\nstream\n%s\nendstream\n", node.Value().(string)), nil
+		w.WriteString("\nstream\n")
+		w.Write(node.Value().([]byte))
+		w.WriteString("\nendstream\n")
 
 	case ast.XREFS:
 		// We don't serialise the xrefs from the AST,
@@ -123,51 +173,166 @@ func (s *AstSerialiser) Serialise(node ast.PdfNode) (string, error) {
 		// (done in the root node serialisation)
 
 	case ast.TRAILER:
-		sb := strings.Builder{}
+		w.WriteString("trailer\n")
 
 		for _, child := range node.Children() {
 			if child.Type() != ast.DICT {
 				continue
 			}
 
-			serialised, _ := s.Serialise(child)
-			sb.WriteString(serialised + " ")
+			if err := s.write(w, child); err != nil {
+				return err
+			}
+
+			w.WriteString(" ")
 		}
 
-		return fmt.Sprintf("trailer\n%s\n", sb.String()), nil
+		w.WriteString("\n")
 
 	case ast.INDIRECT_OBJECT:
-		id := node.(*ast.IndirectObjectNode).Id()
-		generation := node.(*ast.IndirectObjectNode).Gen()
-		sb := strings.Builder{}
+		obj := node.(*ast.IndirectObjectNode)
+		children := streamChildren(obj)
 
-		sb.WriteString(fmt.Sprintf("%d %d obj\n", id, generation))
-		for _, child := range node.Children() {
-			serialised, _ := s.Serialise(child)
-			sb.WriteString(serialised)
+		w.Printf("%d %d obj\n", obj.Id(), obj.Gen())
+
+		for _, child := range children {
+			if err := s.write(w, child); err != nil {
+				return err
+			}
 		}
 
-		return sb.String() + "\nendobj\n", nil
+		w.WriteString("\nendobj\n")
 
 	case ast.OBJECT_REF:
-		return fmt.Sprintf(
+		w.Printf(
 			"%d %d R",
 			node.(*ast.ObjectRefNode).Id(),
 			node.(*ast.ObjectRefNode).Gen(),
-		), nil
+		)
+
+	default:
+		return fmt.Errorf("unknown node type: %d", node.Type())
+	}
+
+	return w.err
+}
+
+// writeDocument writes a whole document: the header, each indirect object and
+// then a cross-reference section and trailer generated from them
+func (s *AstSerialiser) writeDocument(w *countingWriter, node ast.PdfNode) error {
+	w.Printf("%%%s\n", node.Value())
+
+	entries := map[int64]ast.XRefEntry{}
+	packed := []*ast.IndirectObjectNode{}
+	var trailerNode ast.PdfNode
+	encrypt := int64(-1)
+
+	if s.objectStreams {
+		// A nil *ast.TrailerNode isn't a nil ast.PdfNode, so is checked here
+		if root, ok := node.(*ast.RootNode); ok && root.GetTrailer() != nil {
+			encrypt = encryptId(trailerDict(root.GetTrailer()))
+		}
+	}
+
+	for _, child := range node.Children() {
+		switch child.Type() {
+		case ast.INDIRECT_OBJECT:
+			obj := child.(*ast.IndirectObjectNode)
+
+			if err := checkObjectNumber(obj); err != nil {
+				return err
+			}
+
+			if s.objectStreams && canPack(obj, encrypt) {
+				packed = append(packed, obj)
+				continue
+			}
+
+			entries[obj.Id()] = inUseEntry(obj, w.n)
+
+		case ast.TRAILER:
+			// The trailer is written after the xref table
+			trailerNode = child
+			continue
+		}
+
+		if err := s.write(w, child); err != nil {
+			return err
+		}
+	}
+
+	if err := s.writeObjectStreams(w, packed, 0, entries); err != nil {
+		return err
 	}
 
-	return "", fmt.Errorf("unknown node type: %d", node.Type())
+	xrefTableStartOffset := w.n
+
+	if s.xrefStream {
+		// The xref stream is itself an object, numbered after all the others
+		id := highestId(entries) + 1
+
+		xrefStream, err := s.createXrefStream(
+			id,
+			entries,
+			newTrailer(trailerDict(trailerNode), id+1, -1),
+			xrefTableStartOffset,
+			true,
+		)
+
+		if err != nil {
+			return err
+		}
+
+		if err := s.write(w, xrefStream); err != nil {
+			return err
+		}
+
+		w.Printf("startxref\n%d\n%%%%EOF", xrefTableStartOffset)
+		return w.err
+	}
+
+	w.WriteString(createXrefTable(entries))
+	w.WriteString("\n")
+
+	trailer := ast.NewTrailerNode()
+	trailer.AddChild(newTrailer(trailerDict(trailerNode), highestId(entries)+1, -1))
+
+	if err := s.write(w, trailer); err != nil {
+		return err
+	}
+
+	w.Printf("\nstartxref\n%d\n%%%%EOF", xrefTableStartOffset)
+	return w.err
 }
 
-// createXrefTable creates a new xref table from a list of byte offsets for the
-// indirect objects in the PDF
-func createXrefTable(offsets []int) string {
+// escapeString escapes a string so that it can be written as a string literal.
+// Parentheses and backslashes are escaped, as is anything that isn't printable
+// ASCII, so the literal reads back as exactly the same bytes.
+func escapeString(str []byte) string {
 	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f\n", len(offsets)+1))
 
-	for _, offset := range offsets {
-		sb.WriteString(fmt.Sprintf("%010d 00000 n\n", offset))
+	for i := 0; i < len(str); i++ {
+		ch := str[i]
+
+		switch {
+		case ch == '(' || ch == ')' || ch == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(ch)
+		case ch == '\n':
+			sb.WriteString("\\n")
+		case ch == '\r':
+			sb.WriteString("\\r")
+		case ch == '\t':
+			sb.WriteString("\\t")
+		case ch == '\b':
+			sb.WriteString("\\b")
+		case ch == '\f':
+			sb.WriteString("\\f")
+		case ch < 0x20 || ch > 0x7E:
+			sb.WriteString(fmt.Sprintf("\\%03o", ch))
+		default:
+			sb.WriteByte(ch)
+		}
 	}
 
 	return sb.String()
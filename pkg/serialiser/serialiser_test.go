@@ -0,0 +1,369 @@
+package serialiser_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/document"
+	"github.com/rgracey/pdf/pkg/parser"
+	"github.com/rgracey/pdf/pkg/serialiser"
+	"github.com/rgracey/pdf/pkg/tokeniser"
+)
+
+func TestSerialiser_RoundTripsHexStrings(t *testing.T) {
+	input := "[<B5A4D3F7E1C9E8A5D3C0F5E4B3A2C1D0> <0A1B2C3D4E5F60718293A4B5C6D7E8F9>]"
+
+	expectRoundTrip(t, input)
+	expectRoundTrip(t, "[<b5a4d3f7e1c9e8a5d3c0f5e4b3a2c1d0> <0a1b2c3d4e5f60718293a4b5c6d7e8f9>]")
+	expectRoundTrip(t, "[<0aBc> <b5A4d3F7>]")
+}
+
+func TestSerialiser_UppercasesEditedHexStrings(t *testing.T) {
+	str := parseObject(t, "<0a0b>").(*ast.StringNode)
+	str.SetValue([]byte{0x0c})
+
+	expectSerialised(t, str, "<0C>")
+}
+
+func TestSerialiser_SerialisesStrings(t *testing.T) {
+	expectSerialised(t, ast.NewHexStringNode([]byte("Hello")), "<48656C6C6F>")
+	expectSerialised(t, ast.NewStringNode([]byte("Hello")), "(Hello)")
+}
+
+func TestSerialiser_SerialisesNull(t *testing.T) {
+	expectSerialised(t, ast.NewNullNode(), "null")
+	expectRoundTrip(t, "[null 1 null]")
+}
+
+func TestSerialiser_EscapesStrings(t *testing.T) {
+	expectSerialised(
+		t,
+		ast.NewStringNode([]byte("a (b) \\ c\n\r\t\b\f\x00\xff")),
+		`(a \(b\) \\ c\n\r\t\b\f\000\377)`,
+	)
+}
+
+func TestSerialiser_RoundTripsStrings(t *testing.T) {
+	expectRoundTrip(t, `(a \(b\) \\ c\n\r\t\b\f\000\377)`)
+	expectReparsed(t, `(a (nested) \053 string \
+with a continuation)`)
+}
+
+func TestSerialiser_WritesXRefStreams(t *testing.T) {
+	input := `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 3 0 R >>
+endobj
+3 0 obj
+<< /Type /Pages /Kids [] /Count 0 >>
+endobj
+trailer
+<< /Size 4 /Root 1 0 R /ID [<01> <02>] >>
+`
+
+	root, err := parser.NewParser(
+		tokeniser.NewTokeniser(strings.NewReader(input)),
+	).Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	serialised, err := serialiser.NewSerialiser(serialiser.WithXRefStream()).Serialise(root)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Contains(serialised, "trailer") || strings.Contains(serialised, "\nxref\n") {
+		t.Errorf("Expected no xref table or trailer, got %q", serialised)
+	}
+
+	doc, err := document.NewDocument(strings.NewReader(serialised), int64(len(serialised)))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if doc.Trailer().Get("ID") == nil {
+		t.Errorf("Expected /ID to be carried over from the trailer")
+	}
+
+	if doc.Trailer().Get("Size").Value() != int64(5) {
+		t.Errorf("Expected /Size 5, got %v", doc.Trailer().Get("Size").Value())
+	}
+
+	for _, id := range []int64{1, 3} {
+		if obj, err := doc.Object(id); err != nil || obj.Id() != id {
+			t.Errorf("Expected object %d, got %v (%v)", id, obj, err)
+		}
+	}
+
+	if entry, ok := doc.XRefs().Entry(2); !ok || entry.Type != ast.XREF_FREE {
+		t.Errorf("Expected object 2 to be free, got %v", entry)
+	}
+}
+
+func TestSerialiser_WritesObjectStreams(t *testing.T) {
+	input := `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [] /Count 0 >>
+endobj
+3 0 obj
+<< /Length 5 >>
+stream
+hello
+endstream
+endobj
+4 1 obj
+(old generation)
+endobj
+5 0 obj
+<< /Filter /Standard >>
+endobj
+trailer
+<< /Size 6 /Root 1 0 R /Encrypt 5 0 R >>
+`
+
+	root, err := parser.NewParser(
+		tokeniser.NewTokeniser(strings.NewReader(input)),
+	).Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	serialised, err := serialiser.NewSerialiser(serialiser.WithObjectStreams()).Serialise(root)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Streams, objects with a non-zero generation and the encryption
+	// dictionary can't be packed
+	for _, header := range []string{"3 0 obj", "4 1 obj", "5 0 obj"} {
+		if !strings.Contains(serialised, header) {
+			t.Errorf("Expected %s to be written directly", header)
+		}
+	}
+
+	for _, header := range []string{"1 0 obj", "2 0 obj"} {
+		if strings.Contains(serialised, header) {
+			t.Errorf("Expected %s to be packed into an object stream", header)
+		}
+	}
+
+	doc, err := document.NewDocument(strings.NewReader(serialised), int64(len(serialised)))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, id := range []int64{1, 2} {
+		if entry, ok := doc.XRefs().Entry(id); !ok || entry.Type != ast.XREF_COMPRESSED {
+			t.Errorf("Expected object %d to be compressed, got %v", id, entry)
+		}
+	}
+
+	for _, id := range []int64{1, 2, 3, 4, 5} {
+		if obj, err := doc.Object(id); err != nil || obj.Id() != id {
+			t.Errorf("Expected object %d, got %v (%v)", id, obj, err)
+		}
+	}
+
+	pages, _ := doc.Object(2)
+
+	if pages.Children()[0].(*ast.DictNode).Get("Type").Value() != "Pages" {
+		t.Errorf("Expected the pages dictionary, got %v", pages.Children())
+	}
+}
+
+func TestSerialiser_WritesObjectStreamsWithoutTrailer(t *testing.T) {
+	root := parseDocument(t, "%PDF-1.7\n1 0 obj\n<< /Type /Catalog >>\nendobj\n")
+
+	serialised, err := serialiser.NewSerialiser(serialiser.WithObjectStreams()).Serialise(root)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(serialised, "/Type /ObjStm") {
+		t.Errorf("Expected an object stream, got %q", serialised)
+	}
+}
+
+func TestSerialiser_SerialiseToMatchesSerialise(t *testing.T) {
+	root := parseDocument(t, "%PDF-1.7\n1 0 obj\n<< /Length 4 >>\nstream\n\xe2\x82\xac!\nendstream\nendobj\n"+
+		"2 0 obj\n(caf\xc3\xa9)\nendobj\ntrailer\n<< /Root 1 0 R >>\n")
+
+	s := serialiser.NewSerialiser()
+	expected, err := s.Serialise(root)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	buf := bytes.Buffer{}
+
+	if err := s.SerialiseTo(&buf, root); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	// Offsets are in bytes, so the objects after multi-byte content are
+	// still found
+	doc, err := document.NewDocument(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := doc.Object(2); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestSerialiser_SerialiseToReturnsWriteErrors(t *testing.T) {
+	root := parseDocument(t, "%PDF-1.7\n1 0 obj\n(one)\nendobj\n")
+	w := &failingWriter{limit: 20}
+
+	if err := serialiser.NewSerialiser().SerialiseTo(w, root); err != errWriteFailed {
+		t.Errorf("Expected errWriteFailed, got %v", err)
+	}
+}
+
+func TestSerialiser_CorrectsStreamLength(t *testing.T) {
+	serialised := serialiseDocument(t, "%PDF-1.7\n1 0 obj\n<< /Length 99 >>\nstream\nhello\nendstream\nendobj\n")
+
+	if !strings.Contains(serialised, "<</Length 5 >>") {
+		t.Errorf("Expected /Length 5, got %q", serialised)
+	}
+}
+
+func TestSerialiser_SimplifiesStreamFilters(t *testing.T) {
+	serialised := serialiseDocument(t, "%PDF-1.7\n1 0 obj\n"+
+		"<< /Length 5 /Filter [/FlateDecode] /DecodeParms [null] >>\nstream\nhello\nendstream\nendobj\n")
+
+	if !strings.Contains(serialised, "<</Length 5 /Filter /FlateDecode >>") {
+		t.Errorf("Expected a single /Filter without /DecodeParms, got %q", serialised)
+	}
+
+	// Parameters for a filter are kept
+	serialised = serialiseDocument(t, "%PDF-1.7\n1 0 obj\n"+
+		"<< /Length 5 /Filter [/ASCIIHexDecode /LZWDecode] /DecodeParms [null << /EarlyChange 0 >>] >>\n"+
+		"stream\nhello\nendstream\nendobj\n")
+
+	if !strings.Contains(serialised, "/DecodeParms [null <</EarlyChange 0 >>]") {
+		t.Errorf("Expected /DecodeParms to be kept, got %q", serialised)
+	}
+}
+
+func TestSerialiser_KeepsFiltersItCantSimplify(t *testing.T) {
+	for _, dict := range []string{
+		"/Filter [/FlateDecode /ASCIIHexDecode] /DecodeParms [null]",
+		"/Filter (FlateDecode) /DecodeParms [null]",
+	} {
+		serialised := serialiseDocument(t, "%PDF-1.7\n1 0 obj\n<< /Length 99 "+dict+" >>\n"+
+			"stream\nhello\nendstream\nendobj\n")
+
+		if !strings.Contains(serialised, "/Length 5 ") || !strings.Contains(serialised, "/DecodeParms [null]") {
+			t.Errorf("Expected /Length 5 with the filters unchanged, got %q", serialised)
+		}
+	}
+}
+
+// serialiseDocument parses and serialises a document
+func serialiseDocument(t *testing.T, input string) string {
+	t.Helper()
+
+	serialised, err := serialiser.NewSerialiser().Serialise(parseDocument(t, input))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return serialised
+}
+
+var errWriteFailed = errors.New("write failed")
+
+// failingWriter fails once more than limit bytes have been written
+type failingWriter struct {
+	limit   int
+	written int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.limit {
+		return 0, errWriteFailed
+	}
+
+	w.written += len(p)
+	return len(p), nil
+}
+
+// expectReparsed parses a single object, serialises it and parses it again,
+// checking the value is unchanged
+func expectReparsed(t *testing.T, input string) {
+	t.Helper()
+
+	first := parseObject(t, input)
+	serialised, err := serialiser.NewSerialiser().Serialise(first)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	second := parseObject(t, serialised)
+
+	if !bytes.Equal(first.Value().([]byte), second.Value().([]byte)) {
+		t.Errorf("Expected %q, got %q", first.Value(), second.Value())
+	}
+}
+
+// expectRoundTrip parses a single object and checks it serialises back to the
+// same input
+func expectRoundTrip(t *testing.T, input string) {
+	t.Helper()
+
+	expectSerialised(t, parseObject(t, input), input)
+}
+
+// parseObject parses the input and returns the first object in it
+func parseObject(t *testing.T, input string) ast.PdfNode {
+	t.Helper()
+
+	root, err := parser.NewParser(
+		tokeniser.NewTokeniser(strings.NewReader(input)),
+	).Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return root.Children()[0]
+}
+
+// expectSerialised checks that a node serialises to the expected output
+func expectSerialised(t *testing.T, node ast.PdfNode, expected string) {
+	t.Helper()
+
+	actual, err := serialiser.NewSerialiser().Serialise(node)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if actual != expected {
+		t.Errorf("Expected %q, got %q", expected, actual)
+	}
+}
@@ -0,0 +1,152 @@
+package serialiser
+
+import "github.com/rgracey/pdf/pkg/ast"
+
+// streamChildren returns the children of an indirect object to write. For a
+// stream object, the dictionary is replaced by a copy with /Length set to the
+// length of the stream data, and /Filter and /DecodeParms in their simplest
+// form: a single filter as a name rather than an array, and no parameters
+// where every filter uses the defaults.
+func streamChildren(obj *ast.IndirectObjectNode) []ast.PdfNode {
+	children := obj.Children()
+	stream, err := ast.Stream(obj)
+
+	if err != nil {
+		return children
+	}
+
+	for i, child := range children {
+		dict, ok := child.(*ast.DictNode)
+
+		if !ok {
+			continue
+		}
+
+		children = append([]ast.PdfNode{}, children...)
+		children[i] = normaliseStreamDict(dict, int64(len(stream.Value().([]byte))))
+		break
+	}
+
+	return children
+}
+
+// normaliseStreamDict returns a copy of a stream dictionary for data of the
+// given length. Filters and parameters that are indirect or don't make sense
+// together are copied as they are, so that writing a document never fails
+// because of them.
+func normaliseStreamDict(dict *ast.DictNode, length int64) *ast.DictNode {
+	normalised := ast.NewDictNode()
+	copyEntries(normalised, dict, nil)
+	normalised.Set("Length", ast.NewIntegerNode(length))
+
+	filter := normalised.Get("Filter")
+	params := normalised.Get("DecodeParms")
+
+	if isRef(filter) || isRef(params) {
+		return normalised
+	}
+
+	names, ok := filterNames(filter)
+
+	if !ok {
+		return normalised
+	}
+
+	paramsList, ok := filterParams(params, len(names))
+
+	if !ok {
+		return normalised
+	}
+
+	if len(names) == 0 {
+		normalised.Delete("Filter")
+		normalised.Delete("DecodeParms")
+		return normalised
+	}
+
+	normalised.Set("Filter", arrayOrSingle(names))
+
+	for _, p := range paramsList {
+		if p.Type() != ast.NULL {
+			normalised.Set("DecodeParms", arrayOrSingle(paramsList))
+			return normalised
+		}
+	}
+
+	normalised.Delete("DecodeParms")
+	return normalised
+}
+
+func isRef(node ast.PdfNode) bool {
+	return node != nil && node.Type() == ast.OBJECT_REF
+}
+
+// filterNames returns the filters given by /Filter, which may be a name or an
+// array of names, or false if it's neither
+func filterNames(filter ast.PdfNode) ([]ast.PdfNode, bool) {
+	nodes := []ast.PdfNode{}
+
+	switch filter := filter.(type) {
+	case nil, *ast.NullNode:
+		return nodes, true
+	case *ast.ArrayNode:
+		nodes = filter.Children()
+	default:
+		nodes = append(nodes, filter)
+	}
+
+	for _, node := range nodes {
+		if node.Type() != ast.NAME && !isRef(node) {
+			return nil, false
+		}
+	}
+
+	return nodes, true
+}
+
+// filterParams returns the parameters given by /DecodeParms for each of n
+// filters, with null for a filter using the defaults, or false if they don't
+// match up with the filters
+func filterParams(params ast.PdfNode, n int) ([]ast.PdfNode, bool) {
+	nodes := []ast.PdfNode{}
+
+	switch params := params.(type) {
+	case nil, *ast.NullNode:
+		for i := 0; i < n; i++ {
+			nodes = append(nodes, ast.NewNullNode())
+		}
+
+		return nodes, true
+	case *ast.ArrayNode:
+		nodes = params.Children()
+	default:
+		nodes = append(nodes, params)
+	}
+
+	if len(nodes) != n {
+		return nil, false
+	}
+
+	for _, node := range nodes {
+		if node.Type() != ast.DICT && node.Type() != ast.NULL && !isRef(node) {
+			return nil, false
+		}
+	}
+
+	return nodes, true
+}
+
+// arrayOrSingle returns the only node given, or an array of the nodes
+func arrayOrSingle(nodes []ast.PdfNode) ast.PdfNode {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+
+	array := ast.NewArrayNode()
+
+	for _, node := range nodes {
+		array.AddChild(node)
+	}
+
+	return array
+}
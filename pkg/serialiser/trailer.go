@@ -0,0 +1,65 @@
+package serialiser
+
+import "github.com/rgracey/pdf/pkg/ast"
+
+// Trailer entries that describe a cross-reference section rather than the
+// document, so aren't carried over into the trailer of a new section
+var sectionKeys = map[string]bool{
+	"Type":        true,
+	"Size":        true,
+	"Index":       true,
+	"W":           true,
+	"Prev":        true,
+	"XRefStm":     true,
+	"Length":      true,
+	"Filter":      true,
+	"DecodeParms": true,
+}
+
+// newTrailer creates the trailer dictionary for a new cross-reference section
+// of the given size, carrying over the entries of an existing trailer (if any)
+// that describe the document. If prev isn't -1 it is given as the offset of
+// the previous section.
+func newTrailer(trailer *ast.DictNode, size int64, prev int64) *ast.DictNode {
+	dict := ast.NewDictNode()
+
+	if trailer != nil {
+		copyEntries(dict, trailer, sectionKeys)
+	}
+
+	dict.Set("Size", ast.NewIntegerNode(size))
+
+	if prev != -1 {
+		dict.Set("Prev", ast.NewIntegerNode(prev))
+	}
+
+	return dict
+}
+
+// trailerDict returns the dictionary of a trailer node, or nil if there isn't
+// one
+func trailerDict(trailer ast.PdfNode) *ast.DictNode {
+	if trailer == nil {
+		return nil
+	}
+
+	for _, child := range trailer.Children() {
+		if dict, ok := child.(*ast.DictNode); ok {
+			return dict
+		}
+	}
+
+	return nil
+}
+
+// copyEntries copies the entries of a dictionary into another, skipping the
+// given keys
+func copyEntries(to *ast.DictNode, from *ast.DictNode, skip map[string]bool) {
+	from.Range(func(key string, value ast.PdfNode) bool {
+		if !skip[key] {
+			to.Set(key, value)
+		}
+
+		return true
+	})
+}
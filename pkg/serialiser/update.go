@@ -0,0 +1,132 @@
+package serialiser
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/document"
+)
+
+// Update serialises an incremental update to the original document, holding
+// the given new or modified objects followed by a cross-reference section and
+// trailer that point back to those of the original. Only the update is
+// returned. Appending it to the original leaves the original bytes, and so any
+// digital signatures over them, untouched.
+//
+// The cross-reference section is a stream if the original's latest section
+// was, or if the options ask for one.
+func Update(original []byte, objects []*ast.IndirectObjectNode, options ...Option) (string, error) {
+	sb := strings.Builder{}
+
+	if err := UpdateTo(&sb, original, objects, options...); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// UpdateTo is like Update, but writes the update to a writer
+func UpdateTo(
+	w io.Writer,
+	original []byte,
+	objects []*ast.IndirectObjectNode,
+	options ...Option,
+) error {
+	doc, err := document.NewDocument(bytes.NewReader(original), int64(len(original)))
+
+	if err != nil {
+		return err
+	}
+
+	s := &AstSerialiser{}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	revisions := doc.Revisions()
+	latest := revisions[len(revisions)-1]
+
+	if name, ok := latest.Trailer.Get("Type").(*ast.NameNode); ok && name.Value() == "XRef" {
+		s.xrefStream = true
+	}
+
+	// Offsets carry on from the end of the original
+	cw := newCountingWriter(w, int64(len(original)))
+
+	// The update has to start on a line of its own
+	if last := len(original) - 1; last >= 0 && original[last] != '\n' && original[last] != '\r' {
+		cw.WriteString("\n")
+	}
+
+	// New objects (including any object or xref streams) are numbered from
+	// the original's /Size
+	size := int64(0)
+
+	if value, ok := doc.Trailer().Get("Size").(*ast.IntegerNode); ok {
+		size = value.Value().(int64)
+	}
+
+	encrypt := encryptId(doc.Trailer())
+	entries := map[int64]ast.XRefEntry{}
+	packed := []*ast.IndirectObjectNode{}
+
+	for _, obj := range objects {
+		if err := checkObjectNumber(obj); err != nil {
+			return err
+		}
+
+		if s.objectStreams && canPack(obj, encrypt) {
+			packed = append(packed, obj)
+			continue
+		}
+
+		entries[obj.Id()] = inUseEntry(obj, cw.n)
+
+		if err := s.write(cw, obj); err != nil {
+			return err
+		}
+	}
+
+	if err := s.writeObjectStreams(cw, packed, size, entries); err != nil {
+		return err
+	}
+
+	if next := highestId(entries) + 1; next > size {
+		size = next
+	}
+
+	offset := cw.n
+
+	if s.xrefStream {
+		xrefStream, err := s.createXrefStream(
+			size,
+			entries,
+			newTrailer(doc.Trailer(), size+1, latest.XRefOffset),
+			offset,
+			false,
+		)
+
+		if err != nil {
+			return err
+		}
+
+		if err := s.write(cw, xrefStream); err != nil {
+			return err
+		}
+	} else {
+		trailer := ast.NewTrailerNode()
+		trailer.AddChild(newTrailer(doc.Trailer(), size, latest.XRefOffset))
+
+		cw.WriteString(createXrefSection(entries))
+
+		if err := s.write(cw, trailer); err != nil {
+			return err
+		}
+	}
+
+	cw.Printf("startxref\n%d\n%%%%EOF\n", offset)
+	return cw.err
+}
@@ -0,0 +1,184 @@
+package serialiser_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/document"
+	"github.com/rgracey/pdf/pkg/parser"
+	"github.com/rgracey/pdf/pkg/serialiser"
+	"github.com/rgracey/pdf/pkg/tokeniser"
+)
+
+const original = `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [] /Count 0 >>
+endobj
+xref
+0 3
+0000000000 65535 f
+0000000009 00000 n
+0000000058 00000 n
+trailer
+<< /Size 3 /Root 1 0 R /ID [<01> <02>] >>
+startxref
+110
+%%EOF`
+
+func TestUpdate_AppendsObjects(t *testing.T) {
+	pages := ast.NewIndirectObjectNode(2, 0)
+	pages.AddChild(parseObject(t, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>"))
+
+	page := ast.NewIndirectObjectNode(3, 0)
+	page.AddChild(parseObject(t, "<< /Type /Page /Parent 2 0 R >>"))
+
+	update, err := serialiser.Update([]byte(original), []*ast.IndirectObjectNode{pages, page})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Contains(update, "1 0 obj") {
+		t.Errorf("Expected unchanged objects not to be written, got %q", update)
+	}
+
+	if !strings.Contains(update, "xref\n2 2\n") {
+		t.Errorf("Expected a subsection for objects 2 and 3, got %q", update)
+	}
+
+	doc := openUpdated(t, original+update)
+
+	if revisions := doc.Revisions(); len(revisions) != 2 || revisions[0].End != int64(len(original)+1) {
+		t.Errorf("Expected the original to be left as the first revision, got %v", revisions)
+	}
+
+	trailer := doc.Trailer()
+
+	if trailer.Get("Prev").Value() != int64(110) {
+		t.Errorf("Expected /Prev 110, got %v", trailer.Get("Prev"))
+	}
+
+	if trailer.Get("Size").Value() != int64(4) {
+		t.Errorf("Expected /Size 4, got %v", trailer.Get("Size"))
+	}
+
+	if trailer.Get("Root") == nil || trailer.Get("ID") == nil {
+		t.Errorf("Expected /Root and /ID to be carried over, got %v", trailer.Children())
+	}
+
+	updated, err := doc.Object(2)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if updated.Children()[0].(*ast.DictNode).Get("Count").Value() != int64(1) {
+		t.Errorf("Expected the updated pages object, got %v", updated.Children())
+	}
+
+	for _, id := range []int64{1, 3} {
+		if _, err := doc.Object(id); err != nil {
+			t.Errorf("Unexpected error for object %d: %v", id, err)
+		}
+	}
+}
+
+func TestUpdate_UsesXRefStreamsLikeTheOriginal(t *testing.T) {
+	root := parseDocument(t, original)
+	serialised, err := serialiser.NewSerialiser(serialiser.WithXRefStream()).Serialise(root)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	page := ast.NewIndirectObjectNode(5, 0)
+	page.AddChild(parseObject(t, "<< /Type /Page /Parent 2 0 R >>"))
+
+	update, err := serialiser.Update([]byte(serialised), []*ast.IndirectObjectNode{page})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Contains(update, "trailer") {
+		t.Errorf("Expected an xref stream rather than a table, got %q", update)
+	}
+
+	doc := openUpdated(t, serialised+update)
+
+	if _, err := doc.Object(5); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if _, err := doc.Object(1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// The original xref stream was object 3, so the new one follows object 5
+	if doc.Trailer().Get("Size").Value() != int64(7) {
+		t.Errorf("Expected /Size 7, got %v", doc.Trailer().Get("Size"))
+	}
+}
+
+func TestUpdate_PacksObjectStreams(t *testing.T) {
+	pages := ast.NewIndirectObjectNode(2, 0)
+	pages.AddChild(parseObject(t, "<< /Type /Pages /Kids [] /Count 0 /Updated true >>"))
+
+	update, err := serialiser.Update(
+		[]byte(original),
+		[]*ast.IndirectObjectNode{pages},
+		serialiser.WithObjectStreams(),
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	doc := openUpdated(t, original+update)
+
+	if entry, ok := doc.XRefs().Entry(2); !ok || entry.Type != ast.XREF_COMPRESSED {
+		t.Errorf("Expected object 2 to be compressed, got %v", entry)
+	}
+
+	updated, err := doc.Object(2)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if updated.Children()[0].(*ast.DictNode).Get("Updated") == nil {
+		t.Errorf("Expected the updated pages object, got %v", updated.Children())
+	}
+}
+
+// openUpdated opens a serialised document, failing the test on error
+func openUpdated(t *testing.T, input string) *document.Document {
+	t.Helper()
+
+	doc, err := document.NewDocument(strings.NewReader(input), int64(len(input)))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return doc
+}
+
+// parseDocument parses a whole document, failing the test on error
+func parseDocument(t *testing.T, input string) ast.PdfNode {
+	t.Helper()
+
+	root, err := parser.NewParser(
+		tokeniser.NewTokeniser(strings.NewReader(input)),
+	).Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return root
+}
@@ -0,0 +1,41 @@
+package serialiser
+
+import (
+	"fmt"
+	"io"
+)
+
+// countingWriter counts the bytes written through it, so that the offsets of
+// objects are known without holding on to the output. Once a write fails the
+// error is kept and later writes are skipped, so callers can check it once.
+type countingWriter struct {
+	w   io.Writer
+	n   int64 // Bytes written so far
+	err error // The first write error
+}
+
+func newCountingWriter(w io.Writer, offset int64) *countingWriter {
+	return &countingWriter{w: w, n: offset}
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+
+	return n, err
+}
+
+// WriteString writes a string
+func (cw *countingWriter) WriteString(s string) {
+	io.WriteString(cw, s)
+}
+
+// Printf writes a formatted string
+func (cw *countingWriter) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(cw, format, args...)
+}
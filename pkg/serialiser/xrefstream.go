@@ -0,0 +1,125 @@
+package serialiser
+
+import (
+	"bytes"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/filters"
+)
+
+// createXrefStream creates a cross-reference stream object with the given id
+// for the given entries, to be written at the given offset. The
+// entries of the trailer dictionary are included in the stream dictionary. If
+// complete is true the stream covers every object up to the highest numbered,
+// with those without an entry written as free, otherwise only the given
+// entries are written.
+func (s *AstSerialiser) createXrefStream(
+	id int64,
+	entries map[int64]ast.XRefEntry,
+	trailer *ast.DictNode,
+	offset int64,
+	complete bool,
+) (*ast.IndirectObjectNode, error) {
+	entries[id] = ast.XRefEntry{Id: id, Offset: offset, Type: ast.XREF_IN_USE}
+
+	index := subsections(entries)
+
+	if complete {
+		addFreeEntries(entries, id+1)
+		index = []int64{0, id + 1}
+	}
+
+	data, widths := encodeXrefEntries(entries, index)
+	compressed, err := filters.FlateEncode(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dict := ast.NewDictNode()
+	copyEntries(dict, trailer, nil)
+
+	w := ast.NewArrayNode()
+
+	for _, width := range widths {
+		w.AddChild(ast.NewIntegerNode(width))
+	}
+
+	dict.Set("Type", ast.NewNameNode("XRef"))
+	dict.Set("W", w)
+
+	if !complete {
+		indexArray := ast.NewArrayNode()
+
+		for _, value := range index {
+			indexArray.AddChild(ast.NewIntegerNode(value))
+		}
+
+		dict.Set("Index", indexArray)
+	}
+
+	dict.Set("Filter", ast.NewNameNode("FlateDecode"))
+	dict.Set("Length", ast.NewIntegerNode(int64(len(compressed))))
+
+	obj := ast.NewIndirectObjectNode(id, 0)
+	obj.AddChild(dict)
+	obj.AddChild(ast.NewStreamNode(compressed))
+
+	return obj, nil
+}
+
+// encodeXrefEntries encodes the entries for the objects in the given
+// subsections (pairs of first object number and count) in the binary format
+// used by cross-reference streams, returning the encoded entries and the width
+// of each field
+func encodeXrefEntries(entries map[int64]ast.XRefEntry, index []int64) ([]byte, []int64) {
+	maxOffset := int64(0)
+
+	for _, entry := range entries {
+		if entry.Offset > maxOffset {
+			maxOffset = entry.Offset
+		}
+	}
+
+	widths := []int64{1, fieldWidth(maxOffset), 2}
+	buf := bytes.Buffer{}
+
+	for i := 0; i+1 < len(index); i += 2 {
+		for id := index[i]; id < index[i]+index[i+1]; id++ {
+			entry := entries[id]
+			fields := []int64{0, entry.Offset, entry.Gen}
+
+			switch entry.Type {
+			case ast.XREF_IN_USE:
+				fields[0] = 1
+			case ast.XREF_COMPRESSED:
+				fields[0] = 2
+			}
+
+			for j, field := range fields {
+				writeField(&buf, field, widths[j])
+			}
+		}
+	}
+
+	return buf.Bytes(), widths
+}
+
+// fieldWidth returns the number of bytes needed to hold the given value
+func fieldWidth(value int64) int64 {
+	width := int64(1)
+
+	for value > 0xff {
+		value >>= 8
+		width++
+	}
+
+	return width
+}
+
+// writeField writes a big-endian unsigned integer field of the given width
+func writeField(buf *bytes.Buffer, value int64, width int64) {
+	for i := width - 1; i >= 0; i-- {
+		buf.WriteByte(byte(value >> (8 * i)))
+	}
+}
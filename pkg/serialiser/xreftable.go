@@ -0,0 +1,141 @@
+package serialiser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rgracey/pdf/pkg/ast"
+)
+
+// createXrefTable creates a new xref table for the indirect objects in the PDF,
+// covering every object number up to the highest. Numbers without an object
+// are written as free.
+func createXrefTable(entries map[int64]ast.XRefEntry) string {
+	addFreeEntries(entries, highestId(entries)+1)
+	return createXrefSection(entries)
+}
+
+// createXrefSection creates a cross-reference table holding only the given
+// entries, split into subsections of consecutively numbered objects
+func createXrefSection(entries map[int64]ast.XRefEntry) string {
+	sb := strings.Builder{}
+	sb.WriteString("xref\n")
+	index := subsections(entries)
+
+	for i := 0; i+1 < len(index); i += 2 {
+		sb.WriteString(fmt.Sprintf("%d %d\n", index[i], index[i+1]))
+
+		for id := index[i]; id < index[i]+index[i+1]; id++ {
+			sb.WriteString(xrefEntry(entries[id]))
+		}
+	}
+
+	return sb.String()
+}
+
+// xrefEntry formats an entry of a cross-reference table. Each entry is exactly
+// 20 bytes, including the 2 byte end of line.
+func xrefEntry(entry ast.XRefEntry) string {
+	entryType := "n"
+
+	if entry.Type == ast.XREF_FREE {
+		entryType = "f"
+	}
+
+	return fmt.Sprintf("%010d %05d %s\r\n", entry.Offset, entry.Gen, entryType)
+}
+
+// addFreeEntries adds free entries for the object numbers below size that don't
+// have an entry, and links all of the free entries into the free list. Object
+// 0 is the head of the list, each free entry gives the number of the next, and
+// the last points back to object 0.
+func addFreeEntries(entries map[int64]ast.XRefEntry, size int64) {
+	entries[0] = ast.XRefEntry{Id: 0, Gen: 65535, Type: ast.XREF_FREE}
+
+	free := []int64{}
+
+	for id := int64(0); id < size; id++ {
+		entry, ok := entries[id]
+
+		if !ok {
+			entry = ast.XRefEntry{Id: id, Type: ast.XREF_FREE}
+			entries[id] = entry
+		}
+
+		if entry.Type == ast.XREF_FREE {
+			free = append(free, id)
+		}
+	}
+
+	for i, id := range free {
+		entry := entries[id]
+		entry.Offset = 0
+
+		if i+1 < len(free) {
+			entry.Offset = free[i+1]
+		}
+
+		entries[id] = entry
+	}
+}
+
+// checkObjectNumber returns an error if the object can't be given an entry in
+// a cross-reference section
+func checkObjectNumber(obj *ast.IndirectObjectNode) error {
+	if obj.Id() <= 0 {
+		return fmt.Errorf("%w: %d %d obj", ErrInvalidObjectNumber, obj.Id(), obj.Gen())
+	}
+
+	return nil
+}
+
+// inUseEntry returns the cross-reference entry for an object written at the
+// given offset
+func inUseEntry(obj *ast.IndirectObjectNode, offset int64) ast.XRefEntry {
+	return ast.XRefEntry{
+		Id:     obj.Id(),
+		Offset: offset,
+		Gen:    obj.Gen(),
+		Type:   ast.XREF_IN_USE,
+	}
+}
+
+// highestId returns the highest object number of the entries, or 0 if there
+// aren't any
+func highestId(entries map[int64]ast.XRefEntry) int64 {
+	highest := int64(0)
+
+	for id := range entries {
+		if id > highest {
+			highest = id
+		}
+	}
+
+	return highest
+}
+
+// subsections groups the object numbers of the entries into runs of
+// consecutive numbers, returning the first number and length of each run
+func subsections(entries map[int64]ast.XRefEntry) []int64 {
+	ids := []int64{}
+
+	for id := range entries {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	index := []int64{}
+
+	for i, id := range ids {
+		if i > 0 && id == ids[i-1]+1 {
+			index[len(index)-1]++
+			continue
+		}
+
+		index = append(index, id, 1)
+	}
+
+	return index
+}
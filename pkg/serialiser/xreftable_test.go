@@ -0,0 +1,125 @@
+package serialiser_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rgracey/pdf/pkg/ast"
+	"github.com/rgracey/pdf/pkg/serialiser"
+)
+
+func TestSerialiser_WritesXRefTableForObjectNumbers(t *testing.T) {
+	root := parseDocument(t, `%PDF-1.7
+5 2 obj
+(five)
+endobj
+1 0 obj
+<< /Type /Catalog >>
+endobj
+3 0 obj
+(three)
+endobj
+trailer
+<< /Size 2 /Root 1 0 R /Prev 1234 >>
+`)
+
+	serialised, err := serialiser.NewSerialiser().Serialise(root)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	start := strings.Index(serialised, "xref\n")
+	end := strings.Index(serialised, "trailer")
+
+	if start < 0 || end < 0 {
+		t.Fatalf("Expected an xref table and trailer, got %q", serialised)
+	}
+
+	lines := strings.SplitAfter(serialised[start:end], "\n")
+
+	if lines[1] != "0 6\n" {
+		t.Fatalf("Expected a single subsection for objects 0 to 5, got %q", lines[1])
+	}
+
+	entries := lines[2:8]
+
+	for _, entry := range entries {
+		if len(entry) != 20 {
+			t.Errorf("Expected a 20 byte entry, got %q", entry)
+		}
+	}
+
+	// Free objects 0, 2 and 4 form a chain back to object 0
+	expected := []string{
+		"0000000002 65535 f\r\n",
+		fmt.Sprintf("%010d 00000 n\r\n", strings.Index(serialised, "1 0 obj")),
+		"0000000004 00000 f\r\n",
+		fmt.Sprintf("%010d 00000 n\r\n", strings.Index(serialised, "3 0 obj")),
+		"0000000000 00000 f\r\n",
+		fmt.Sprintf("%010d 00002 n\r\n", strings.Index(serialised, "5 2 obj")),
+	}
+
+	for id, entry := range expected {
+		if entries[id] != entry {
+			t.Errorf("Expected entry %d to be %q, got %q", id, entry, entries[id])
+		}
+	}
+
+	doc := openUpdated(t, serialised)
+
+	for _, id := range []int64{1, 3, 5} {
+		if obj, err := doc.Object(id); err != nil || obj.Id() != id {
+			t.Errorf("Expected object %d, got %v (%v)", id, obj, err)
+		}
+	}
+
+	five, _ := doc.Object(5)
+
+	if five.Gen() != 2 {
+		t.Errorf("Expected object 5 to have generation 2, got %d", five.Gen())
+	}
+
+	if doc.Trailer().Get("Size").Value() != int64(6) {
+		t.Errorf("Expected /Size 6, got %v", doc.Trailer().Get("Size"))
+	}
+
+	if doc.Trailer().Get("Prev") != nil {
+		t.Errorf("Expected /Prev to be dropped")
+	}
+
+	if entry, _ := doc.XRefs().Entry(2); entry.Type != ast.XREF_FREE || entry.Offset != 4 {
+		t.Errorf("Expected object 2 to be free and point to object 4, got %v", entry)
+	}
+}
+
+func TestSerialiser_ReturnsErrorForObjectZero(t *testing.T) {
+	root := parseDocument(t, `%PDF-1.7
+0 0 obj
+<< /Type /Catalog >>
+endobj
+1 0 obj
+(one)
+endobj
+trailer
+<< /Size 2 /Root 0 0 R >>
+`)
+
+	for _, options := range [][]serialiser.Option{
+		nil,
+		{serialiser.WithXRefStream()},
+		{serialiser.WithObjectStreams()},
+	} {
+		if _, err := serialiser.NewSerialiser(options...).Serialise(root); !errors.Is(err, serialiser.ErrInvalidObjectNumber) {
+			t.Errorf("Expected ErrInvalidObjectNumber, got %v", err)
+		}
+	}
+
+	zero := []*ast.IndirectObjectNode{ast.NewIndirectObjectNode(0, 0)}
+
+	if _, err := serialiser.Update([]byte(original), zero); !errors.Is(err, serialiser.ErrInvalidObjectNumber) {
+		t.Errorf("Expected ErrInvalidObjectNumber, got %v", err)
+	}
+}
@@ -15,6 +15,8 @@ const (
 
 	BOOLEAN // true false
 
+	NULL // null
+
 	DICT_START // <<
 	DICT_END   // >>
 
@@ -22,6 +24,7 @@ const (
 	ARRAY_END   // ]
 
 	STRING_LITERAL // (the string)
+	HEX_STRING     // <4E6F762073686D6F7A>
 
 	FUNCTION_START // {
 	FUNCTION_END   // }
@@ -38,8 +41,10 @@ const (
 
 // Token represents a grouping of characters that have a meaning.
 type Token struct {
-	Type  Type
-	Value interface{}
+	Type   Type
+	Value  interface{}
+	Offset int64  // Byte offset of the token in the input
+	Raw    []byte // The text of a hex string between its angle brackets
 }
 
 func (t Token) String() string {
@@ -52,6 +57,8 @@ func (t Token) String() string {
 		tokenType = "COMMENT"
 	case BOOLEAN:
 		tokenType = "BOOLEAN"
+	case NULL:
+		tokenType = "NULL"
 	case NUMBER_INTEGER:
 		tokenType = "INTEGER"
 	case NUMBER_FLOAT:
@@ -66,6 +73,8 @@ func (t Token) String() string {
 		tokenType = "ARRAY_END"
 	case STRING_LITERAL:
 		tokenType = "STRING_LITERAL"
+	case HEX_STRING:
+		tokenType = "HEX_STRING"
 	case FUNCTION_START:
 		tokenType = "FUNCTION_START"
 	case FUNCTION_END:
@@ -74,6 +83,12 @@ func (t Token) String() string {
 		tokenType = "NAME"
 	case KEYWORD:
 		tokenType = "KEYWORD"
+	case STREAM:
+		tokenType = "STREAM"
+	case DELIMITER:
+		tokenType = "DELIMITER"
+	case REGULAR_CHAR:
+		tokenType = "REGULAR_CHAR"
 	}
 
 	return fmt.Sprintf("TYPE: %s, VALUE: %v", tokenType, t.Value)
@@ -6,7 +6,7 @@ import (
 
 var (
 	NUMBER_INTEGER_REGEX = regexp.MustCompile(`^[+-]?\d+$`)
-	NUMBER_FLOAT_REGEX   = regexp.MustCompile(`^[+-]?\d+\.\d+$`)
+	NUMBER_FLOAT_REGEX   = regexp.MustCompile(`^[+-]?(\d+\.\d*|\.\d+)$`)
 )
 
 func isInteger(number string) bool {
@@ -31,7 +31,7 @@ func isFloat(number string) bool {
 //   - 0x7D (right brace) }
 //   - 0x2F (forward slash) /
 //   - 0x25 (percent sign) %
-func isDelimiter(ch rune) bool {
+func isDelimiter(ch byte) bool {
 	switch ch {
 	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
 		return true
@@ -50,7 +50,7 @@ func isDelimiter(ch rune) bool {
 //   - 0x0C (form feed) \f
 //   - 0x0D (carriage return) \r
 //   - 0x20 (space)
-func isWhitespace(ch rune) bool {
+func isWhitespace(ch byte) bool {
 	switch ch {
 	case '\x00', '\t', '\n', '\f', '\r', ' ':
 		return true
@@ -58,3 +58,18 @@ func isWhitespace(ch rune) bool {
 
 	return false
 }
+
+// hexValue returns the value of the given hexadecimal digit, or false if the
+// character is not a hexadecimal digit.
+func hexValue(ch byte) (byte, bool) {
+	switch {
+	case ch >= '0' && ch <= '9':
+		return ch - '0', true
+	case ch >= 'a' && ch <= 'f':
+		return ch - 'a' + 10, true
+	case ch >= 'A' && ch <= 'F':
+		return ch - 'A' + 10, true
+	}
+
+	return 0, false
+}
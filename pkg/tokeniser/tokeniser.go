@@ -2,22 +2,39 @@ package tokeniser
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"io"
 	"strconv"
-	"strings"
 
 	"github.com/rgracey/pdf/pkg/token"
 )
 
+var (
+	ErrUnterminatedString = errors.New("unterminated string literal")
+	ErrUnterminatedStream = errors.New("unterminated stream")
+	ErrUnterminatedImage  = errors.New("unterminated inline image")
+	ErrInvalidHexString   = errors.New("invalid hex string")
+)
+
 // Tokeniser takes raw input characters and breaks them into tokens
 type Tokeniser interface {
 	NextToken() (token.Token, error)
 	UnreadToken()
+
+	// ReadStream reads the body of a stream, directly following the stream
+	// keyword. If the length of the body is known it is given, otherwise -1.
+	ReadStream(length int64) (token.Token, error)
 }
 
 // StreamTokeniser reads characters from an input stream and returns tokens
 type StreamTokeniser struct {
 	r            *bufio.Reader
+	offset       int64               // Byte offset of the next unread character
+	tokenStart   int64               // Byte offset of the token being read
+	last         byte                // The last character read
+	lastRead     bool                // Whether the last read returned a character
+	pending      []byte              // Characters pushed back onto the input, last first
 	readtokens   *Stack[token.Token] // All read tokens
 	unreadTokens *Stack[token.Token] // Any read then unread tokens
 }
@@ -42,9 +59,44 @@ func (t *StreamTokeniser) NextToken() (token.Token, error) {
 	tok, err := t.getToken()
 
 	if err != nil {
-		return token.Token{}, err
+		return tok, err
+	}
+
+	t.readtokens.Push(tok)
+	return tok, nil
+}
+
+// ReadStream reads the body of a stream, directly following the stream keyword.
+// If the length is non-negative then exactly that many bytes are read, so long
+// as they are followed by the endstream keyword. Otherwise (or if the length is
+// wrong) the body is found by scanning for the endstream keyword. The endstream
+// keyword is consumed.
+func (t *StreamTokeniser) ReadStream(length int64) (token.Token, error) {
+	t.tokenStart = t.offset
+	stream, err := t.readStream(length)
+
+	if err != nil {
+		return token.Token{Offset: t.tokenStart}, err
 	}
 
+	tok := token.Token{Type: token.STREAM, Value: stream, Offset: t.tokenStart}
+	t.readtokens.Push(tok)
+	return tok, nil
+}
+
+// ReadInlineImage reads the data of an inline image in a content stream,
+// directly following the ID keyword. If the length of the data is known it is
+// given, otherwise -1 and the data is found by scanning for the EI keyword. The
+// EI keyword is consumed.
+func (t *StreamTokeniser) ReadInlineImage(length int64) (token.Token, error) {
+	t.tokenStart = t.offset
+	data, err := t.readInlineImage(length)
+
+	if err != nil {
+		return token.Token{Offset: t.tokenStart}, err
+	}
+
+	tok := token.Token{Type: token.STREAM, Value: data, Offset: t.tokenStart}
 	t.readtokens.Push(tok)
 	return tok, nil
 }
@@ -61,16 +113,14 @@ func (t *StreamTokeniser) UnreadToken() {
 // getToken reads one or more characters from the input stream and returns a
 // token representing the input
 func (t *StreamTokeniser) getToken() (token.Token, error) {
-	ch, eof := t.read()
-
-	if eof {
-		return token.Token{Type: token.EOF}, nil
-	}
-
-	for isWhitespace(ch) {
-		ch, _ = t.read()
-	}
+	tok, err := t.readToken()
+	tok.Offset = t.tokenStart
+	return tok, err
+}
 
+// readToken does the work for getToken, without recording where the token
+// started
+func (t *StreamTokeniser) readToken() (token.Token, error) {
 	// TODO - This is a bit of a hack
 	// Instead, could add smart String() handling to AST PdfNodes and modify
 	// the adding of children to stream nodes?
@@ -79,8 +129,27 @@ func (t *StreamTokeniser) getToken() (token.Token, error) {
 	if t.readtokens.Length() > 0 &&
 		t.readtokens.Top().Type == token.KEYWORD &&
 		t.readtokens.Top().Value == "stream" {
-		t.unread()
-		return token.Token{Type: token.STREAM, Value: t.readStream()}, nil
+		t.tokenStart = t.offset
+		stream, err := t.readStream(-1)
+
+		if err != nil {
+			return token.Token{}, err
+		}
+
+		return token.Token{Type: token.STREAM, Value: stream}, nil
+	}
+
+	ch, eof := t.read()
+
+	for !eof && isWhitespace(ch) {
+		ch, eof = t.read()
+	}
+
+	t.tokenStart = t.offset - 1
+
+	if eof {
+		t.tokenStart = t.offset
+		return token.Token{Type: token.EOF}, nil
 	}
 
 	switch {
@@ -92,17 +161,18 @@ func (t *StreamTokeniser) getToken() (token.Token, error) {
 			}, nil
 		}
 
+		str, raw, err := t.readHexString()
+
+		if err != nil {
+			return token.Token{}, err
+		}
+
 		return token.Token{
-			Type:  token.DELIMITER,
-			Value: "<",
+			Type:  token.HEX_STRING,
+			Value: str,
+			Raw:   raw,
 		}, nil
 
-		// TODO - Should be a hex string
-		// return token.Token{
-		// 	Type:  token.REGULAR_CHAR,
-		// 	Value: t.readRegularCharacters(),
-		// }, nil
-
 	case ch == '>':
 		if t.maybe('>') {
 			return token.Token{
@@ -141,9 +211,15 @@ func (t *StreamTokeniser) getToken() (token.Token, error) {
 		}, nil
 
 	case ch == '(':
+		str, err := t.readStringLiteral()
+
+		if err != nil {
+			return token.Token{}, err
+		}
+
 		return token.Token{
 			Type:  token.STRING_LITERAL,
-			Value: t.readStringLiteral(),
+			Value: str,
 		}, nil
 
 	case ch == ')':
@@ -169,6 +245,9 @@ func (t *StreamTokeniser) getToken() (token.Token, error) {
 		case tmp == "false":
 			return token.Token{Type: token.BOOLEAN, Value: false}, nil
 
+		case tmp == "null":
+			return token.Token{Type: token.NULL}, nil
+
 		case isInteger(tmp):
 			num, err := strconv.ParseInt(tmp, 10, 64)
 
@@ -192,80 +271,356 @@ func (t *StreamTokeniser) getToken() (token.Token, error) {
 	}
 }
 
+// readComment reads a comment up to the end of the line (or input). The end of
+// line marker is left in the input stream.
 func (l *StreamTokeniser) readComment() string {
-	sb := strings.Builder{}
+	buf := bytes.Buffer{}
 
 	for {
-		ch, _ := l.read()
+		ch, eof := l.read()
+
+		if eof {
+			break
+		}
 
 		if ch == '\r' || ch == '\n' {
-			l.read()
-			c, _ := l.read()
+			l.unread()
+			break
+		}
+
+		buf.WriteByte(ch)
+	}
+
+	return buf.String()
+}
+
+// readStringLiteral reads a string literal from the input stream and returns
+// the decoded bytes. Balanced parentheses are kept as part of the string,
+// escape sequences are decoded and end of line markers are normalised to \n.
+func (l *StreamTokeniser) readStringLiteral() ([]byte, error) {
+	buf := bytes.NewBuffer([]byte{})
+	depth := 0
+
+	for {
+		ch, eof := l.read()
+
+		if eof {
+			return nil, ErrUnterminatedString
+		}
+
+		switch ch {
+		case '(':
+			depth++
+
+		case ')':
+			if depth == 0 {
+				return buf.Bytes(), nil
+			}
+
+			depth--
+
+		case '\r':
+			// CR and CRLF are both treated as a single line feed
+			l.maybe('\n')
+			ch = '\n'
+
+		case '\\':
+			ch, eof = l.read()
+
+			if eof {
+				return nil, ErrUnterminatedString
+			}
 
-			if ch == '\r' && c != '\n' {
-				l.unread()
+			switch ch {
+			case 'n':
+				buf.WriteByte('\n')
+			case 'r':
+				buf.WriteByte('\r')
+			case 't':
+				buf.WriteByte('\t')
+			case 'b':
+				buf.WriteByte('\b')
+			case 'f':
+				buf.WriteByte('\f')
+			case '\r':
+				// Line continuation, neither the backslash nor the end of
+				// line marker are part of the string
+				l.maybe('\n')
+			case '\n':
+				// Line continuation
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				buf.WriteByte(l.readOctalEscape(ch))
+			default:
+				// Includes \( \) and \\, for anything else the backslash
+				// is ignored
+				buf.WriteByte(ch)
 			}
 
+			continue
+		}
+
+		buf.WriteByte(ch)
+	}
+}
+
+// readOctalEscape reads the remainder of an octal escape sequence (\ddd) that
+// starts with the given digit. Up to 3 digits are read, with any overflow
+// ignored.
+func (l *StreamTokeniser) readOctalEscape(first byte) byte {
+	value := first - '0'
+
+	for i := 0; i < 2; i++ {
+		ch, eof := l.read()
+
+		if eof || ch < '0' || ch > '7' {
+			l.unread()
 			break
 		}
 
-		sb.WriteRune(ch)
+		value = value<<3 | (ch - '0')
 	}
 
-	return sb.String()
+	return value
 }
 
-// readStringLiteral reads a string literal from the input stream.
-func (l *StreamTokeniser) readStringLiteral() string {
-	sb := strings.Builder{}
+// readHexString reads a hexadecimal string from the input stream and returns
+// the decoded bytes, along with the text between the angle brackets as it was
+// written. Whitespace between digits is ignored and a missing final digit is
+// assumed to be 0.
+func (l *StreamTokeniser) readHexString() ([]byte, []byte, error) {
+	buf := bytes.NewBuffer([]byte{})
+	raw := []byte{}
+	digits := []byte{}
 
 	for {
-		ch, _ := l.read()
+		ch, eof := l.read()
+
+		if eof {
+			return nil, nil, ErrInvalidHexString
+		}
 
-		if ch == ')' {
+		if ch == '>' {
 			break
 		}
 
-		if ch == '\\' {
-			ch, _ = l.read()
+		raw = append(raw, ch)
+
+		if isWhitespace(ch) {
+			continue
+		}
+
+		digit, ok := hexValue(ch)
+
+		if !ok {
+			return nil, nil, ErrInvalidHexString
+		}
+
+		digits = append(digits, digit)
+
+		if len(digits) == 2 {
+			buf.WriteByte(digits[0]<<4 | digits[1])
+			digits = digits[:0]
+		}
+	}
+
+	if len(digits) == 1 {
+		buf.WriteByte(digits[0] << 4)
+	}
+
+	return buf.Bytes(), raw, nil
+}
+
+// readStream reads the body of a PDF stream. If the length is known (not -1)
+// it is used to read the body, otherwise (or if it turns out to be wrong) the
+// body is read until the endstream keyword. It consumes the endstream keyword
+// and returns the stream body only. The end of line marker following the
+// stream keyword and the one preceding the endstream keyword are not part of
+// the body.
+func (l *StreamTokeniser) readStream(length int64) ([]byte, error) {
+	l.readEOL()
+
+	if length >= 0 {
+		stream := l.readN(length)
+
+		if int64(len(stream)) == length && l.readEndstream() {
+			return stream, nil
 		}
 
-		sb.WriteRune(ch)
+		// The length was wrong, so go back and find the end of the stream
+		// the slow way
+		l.pushback(stream)
 	}
 
-	return sb.String()
+	return l.scanStream()
 }
 
-// readStream reads the body of a PDF stream until it finds the endstream
-// keyword. It consumes the endstream keyword and returns the stream body only.
-func (l *StreamTokeniser) readStream() string {
-	sb := strings.Builder{}
+// scanStream reads the body of a PDF stream until it finds the endstream
+// keyword, consuming the keyword and returning the body only.
+func (l *StreamTokeniser) scanStream() ([]byte, error) {
+	buf := bytes.NewBuffer([]byte{})
+	endstream := []byte("endstream")
 
 	for {
-		ch, _ := l.read()
+		ch, eof := l.read()
 
-		sb.WriteString(string(ch))
+		if eof {
+			return nil, ErrUnterminatedStream
+		}
+
+		buf.WriteByte(ch)
 
-		if strings.HasSuffix(sb.String(), "endstream") {
+		if bytes.HasSuffix(buf.Bytes(), endstream) {
 			break
 		}
 	}
 
-	stream := sb.String()
+	stream := buf.Bytes()
 
 	// Trim endstream keyword
-	stream = stream[:len(stream)-9]
+	stream = stream[:len(stream)-len(endstream)]
 
-	// Trim trailing whitespace
-	stream = strings.TrimRight(stream, " \t\r\n")
+	// Trim the end of line marker
+	switch {
+	case bytes.HasSuffix(stream, []byte("\r\n")):
+		stream = stream[:len(stream)-2]
+	case bytes.HasSuffix(stream, []byte("\n")), bytes.HasSuffix(stream, []byte("\r")):
+		stream = stream[:len(stream)-1]
+	}
 
-	return stream
+	return stream, nil
+}
+
+// readEndstream consumes any whitespace followed by the endstream keyword,
+// returning true if it was found. If it isn't found nothing is consumed.
+func (l *StreamTokeniser) readEndstream() bool {
+	read := []byte{}
+	ch, eof := l.read()
+
+	for !eof && isWhitespace(ch) {
+		read = append(read, ch)
+		ch, eof = l.read()
+	}
+
+	if !eof {
+		read = append(read, ch)
+	}
+
+	for i := 1; i < len("endstream") && !eof; i++ {
+		ch, eof = l.read()
+
+		if !eof {
+			read = append(read, ch)
+		}
+	}
+
+	if bytes.HasSuffix(read, []byte("endstream")) {
+		return true
+	}
+
+	l.pushback(read)
+	return false
+}
+
+// readInlineImage reads the data of an inline image, which starts after a
+// single whitespace character. As with streams, a known length is used if it's
+// followed by the EI keyword, otherwise the data is read until the EI keyword.
+// The whitespace character preceding the keyword is not part of the data.
+func (l *StreamTokeniser) readInlineImage(length int64) ([]byte, error) {
+	if ch, eof := l.read(); !eof && !isWhitespace(ch) {
+		l.unread()
+	}
+
+	if length >= 0 {
+		data := l.readN(length)
+
+		if int64(len(data)) == length && l.readEI() {
+			return data, nil
+		}
+
+		l.pushback(data)
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+
+	for {
+		ch, eof := l.read()
+
+		if eof {
+			return nil, ErrUnterminatedImage
+		}
+
+		buf.WriteByte(ch)
+		data := buf.Bytes()
+
+		// EI must be a keyword of its own, not the start of a longer run of
+		// regular characters
+		if len(data) < 3 || !bytes.HasSuffix(data, []byte("EI")) || !isWhitespace(data[len(data)-3]) {
+			continue
+		}
+
+		if next, eof := l.read(); !eof {
+			l.unread()
+
+			if !isWhitespace(next) && !isDelimiter(next) {
+				continue
+			}
+		}
+
+		return data[:len(data)-3], nil
+	}
+}
+
+// readEI consumes any whitespace followed by the EI keyword, returning true if
+// it was found. If it isn't found nothing is consumed.
+func (l *StreamTokeniser) readEI() bool {
+	read := []byte{}
+	ch, eof := l.read()
+
+	for !eof && isWhitespace(ch) {
+		read = append(read, ch)
+		ch, eof = l.read()
+	}
+
+	if !eof {
+		read = append(read, ch)
+	}
+
+	if ch, eof = l.read(); !eof {
+		read = append(read, ch)
+	}
+
+	if bytes.HasSuffix(read, []byte("EI")) {
+		if next, eof := l.read(); eof || isWhitespace(next) || isDelimiter(next) {
+			l.unread()
+			return true
+		}
+
+		l.unread()
+	}
+
+	l.pushback(read)
+	return false
+}
+
+// readEOL consumes the end of line marker following the stream keyword, which
+// is CRLF or LF. A lone CR isn't an end of line marker here, so is left as the
+// first byte of the stream.
+func (l *StreamTokeniser) readEOL() {
+	if l.maybe('\r') {
+		if !l.maybe('\n') {
+			l.pushback([]byte{'\r'})
+		}
+
+		return
+	}
+
+	l.maybe('\n')
 }
 
 // readRegularCharacters reads "regular" (as defined by the PDF spec) characters
 // from the input stream and returns them as a string.
 func (l *StreamTokeniser) readRegularCharacters() string {
-	sb := strings.Builder{}
+	buf := bytes.Buffer{}
 
 	for {
 		ch, _ := l.read()
@@ -275,37 +630,87 @@ func (l *StreamTokeniser) readRegularCharacters() string {
 			break
 		}
 
-		sb.WriteRune(ch)
+		buf.WriteByte(ch)
 	}
 
-	return sb.String()
+	return buf.String()
 }
 
 // unread unreads the last character read from the input stream so that it can
 // be read again.
 func (t *StreamTokeniser) unread() {
-	t.r.UnreadRune()
+	if !t.lastRead {
+		return
+	}
+
+	t.pending = append(t.pending, t.last)
+	t.offset--
+	t.lastRead = false
+}
+
+// pushback pushes characters back onto the input stream so that they are read
+// again, in order, before anything else.
+func (t *StreamTokeniser) pushback(chars []byte) {
+	for i := len(chars) - 1; i >= 0; i-- {
+		t.pending = append(t.pending, chars[i])
+	}
+
+	t.offset -= int64(len(chars))
+	t.lastRead = false
 }
 
 // read reads the next character in the input stream and returns it. If there
 // are no more characters to read, it returns true to indicate EOF.
-func (t *StreamTokeniser) read() (rune, bool) {
-	ch, _, err := t.r.ReadRune()
-
-	if err != nil {
-		return 0, true
+func (t *StreamTokeniser) read() (byte, bool) {
+	var ch byte
+
+	if len(t.pending) > 0 {
+		ch = t.pending[len(t.pending)-1]
+		t.pending = t.pending[:len(t.pending)-1]
+	} else {
+		var err error
+		ch, err = t.r.ReadByte()
+
+		if err != nil {
+			t.lastRead = false
+			return 0, true
+		}
 	}
 
+	t.offset++
+	t.last = ch
+	t.lastRead = true
 	return ch, false
 }
 
+// readN reads up to n characters from the input stream, returning fewer only
+// if the end of the input is reached.
+func (t *StreamTokeniser) readN(n int64) []byte {
+	buf := bytes.NewBuffer([]byte{})
+
+	for int64(buf.Len()) < n && len(t.pending) > 0 {
+		ch, _ := t.read()
+		buf.WriteByte(ch)
+	}
+
+	copied, _ := io.CopyN(buf, t.r, n-int64(buf.Len()))
+	t.offset += copied
+
+	if buf.Len() > 0 {
+		t.last = buf.Bytes()[buf.Len()-1]
+		t.lastRead = true
+	}
+
+	return buf.Bytes()
+}
+
 // maybe checks the next character in the input stream and returns true if it
 // matches the character passed in and consumes it from the input stream.
 // Otherwise, it returns false and does not consume the character.
-func (t *StreamTokeniser) maybe(ch rune) bool {
-	next, _ := t.read()
+func (t *StreamTokeniser) maybe(ch byte) bool {
+	next, eof := t.read()
 
-	if next != ch {
+	if eof || next != ch {
 		t.unread()
 		return false
 	}
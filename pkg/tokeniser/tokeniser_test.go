@@ -1,6 +1,8 @@
 package tokeniser_test
 
 import (
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -70,7 +72,7 @@ func TestTokeniser_NextToken(t *testing.T) {
 		{Type: token.KEYWORD, Value: "R"},
 		{Type: token.ARRAY_END, Value: "]"},
 		{Type: token.NAME, Value: "Something"},
-		{Type: token.STRING_LITERAL, Value: "This is a string"},
+		{Type: token.STRING_LITERAL, Value: []byte("This is a string")},
 		{Type: token.DICT_END, Value: ">>"},
 		{Type: token.KEYWORD, Value: "endobj"},
 	}
@@ -121,7 +123,87 @@ func TestTokeniser_HandlesStreams(t *testing.T) {
 
 	expected := []token.Token{
 		{Type: token.KEYWORD, Value: "stream"},
-		{Type: token.STREAM, Value: "This is a stream"},
+		{Type: token.STREAM, Value: []byte("This is a stream")},
+	}
+
+	expectTokens(t, tokeniser, expected)
+}
+
+func TestTokeniser_HandlesBinaryStreams(t *testing.T) {
+	pdf := strings.NewReader("stream\r\n\x00\xff\xfe \x80\n\r\nendstream")
+
+	tokeniser := tokeniser.NewTokeniser(pdf)
+
+	expected := []token.Token{
+		{Type: token.KEYWORD, Value: "stream"},
+		{Type: token.STREAM, Value: []byte("\x00\xff\xfe \x80\n")},
+	}
+
+	expectTokens(t, tokeniser, expected)
+}
+
+func TestTokeniser_ReadStream(t *testing.T) {
+	tests := []struct {
+		input    string
+		length   int64
+		expected string
+	}{
+		// Length is used, even if the body contains endstream
+		{"stream\nabc endstream def\nendstream", 17, "abc endstream def"},
+		{"stream\r\nabc endstream def\r\nendstream", 17, "abc endstream def"},
+		{"stream\nabc\n\nendstream", 4, "abc\n"},
+		{"stream\nabc endstream", 3, "abc"},
+		// Too short, too long and unknown lengths fall back to scanning
+		{"stream\nabcdef\nendstream", 3, "abcdef"},
+		{"stream\nabc\nendstream\nendobj", 15, "abc"},
+		{"stream\nabc\nendstream", 100, "abc"},
+		{"stream\r\nabc\r\nendstream", -1, "abc"},
+		// A lone CR after the keyword is data
+		{"stream\rabc\nendstream", 4, "\rabc"},
+		{"stream\n\rabc\nendstream", 4, "\rabc"},
+	}
+
+	for _, test := range tests {
+		tok := tokeniser.NewTokeniser(strings.NewReader(test.input))
+		tok.NextToken()
+
+		actual, err := tok.ReadStream(test.length)
+
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.input, err)
+			continue
+		}
+
+		if string(actual.Value.([]byte)) != test.expected {
+			t.Errorf("Expected %q for %q, got %q", test.expected, test.input, actual.Value)
+		}
+	}
+}
+
+func TestTokeniser_ReadStreamContinuesAfterStream(t *testing.T) {
+	pdf := strings.NewReader("stream\nabcdef\nendstream\nendobj 2 0 obj")
+
+	tokeniser := tokeniser.NewTokeniser(pdf)
+	tokeniser.NextToken()
+	tokeniser.ReadStream(3)
+
+	expected := []token.Token{
+		{Type: token.KEYWORD, Value: "endobj"},
+		{Type: token.NUMBER_INTEGER, Value: int64(2)},
+		{Type: token.NUMBER_INTEGER, Value: int64(0)},
+		{Type: token.KEYWORD, Value: "obj"},
+	}
+
+	expectTokens(t, tokeniser, expected)
+}
+
+func TestTokeniser_HandlesNonUTF8Strings(t *testing.T) {
+	pdf := strings.NewReader("(\xff\xfe\x00A)")
+
+	tokeniser := tokeniser.NewTokeniser(pdf)
+
+	expected := []token.Token{
+		{Type: token.STRING_LITERAL, Value: []byte("\xff\xfe\x00A")},
 	}
 
 	expectTokens(t, tokeniser, expected)
@@ -133,12 +215,74 @@ func TestTokeniser_HandlesStrings(t *testing.T) {
 	tokeniser := tokeniser.NewTokeniser(pdf)
 
 	expected := []token.Token{
-		{Type: token.STRING_LITERAL, Value: "This is a string"},
+		{Type: token.STRING_LITERAL, Value: []byte("This is a string")},
+	}
+
+	expectTokens(t, tokeniser, expected)
+}
+
+func TestTokeniser_HandlesStringEscapes(t *testing.T) {
+	pdf := strings.NewReader(
+		`(a (nested (string)) here) ` +
+			`(\n\r\t\b\f\(\)\\) ` +
+			`(\053\53\0053\400\q) ` +
+			"(line \\\ncontinued \\\r\ntwice) " +
+			"(line\r\nbreaks\rnormalised) " +
+			`(unbalanced \( paren)`,
+	)
+
+	tokeniser := tokeniser.NewTokeniser(pdf)
+
+	expected := []token.Token{
+		{Type: token.STRING_LITERAL, Value: []byte("a (nested (string)) here")},
+		{Type: token.STRING_LITERAL, Value: []byte("\n\r\t\b\f()\\")},
+		{Type: token.STRING_LITERAL, Value: []byte("++\x053\x00q")},
+		{Type: token.STRING_LITERAL, Value: []byte("line continued twice")},
+		{Type: token.STRING_LITERAL, Value: []byte("line\nbreaks\nnormalised")},
+		{Type: token.STRING_LITERAL, Value: []byte("unbalanced ( paren")},
+	}
+
+	expectTokens(t, tokeniser, expected)
+}
+
+func TestTokeniser_HandlesHexStrings(t *testing.T) {
+	pdf := strings.NewReader("<48656C6C6F> <48 65 6c\n6c 6F> <901FA> <> <<")
+
+	tokeniser := tokeniser.NewTokeniser(pdf)
+
+	expected := []token.Token{
+		{Type: token.HEX_STRING, Value: []byte("Hello")},
+		{Type: token.HEX_STRING, Value: []byte("Hello")},
+		{Type: token.HEX_STRING, Value: []byte("\x90\x1f\xa0")},
+		{Type: token.HEX_STRING, Value: []byte("")},
+		{Type: token.DICT_START, Value: "<<"},
 	}
 
 	expectTokens(t, tokeniser, expected)
 }
 
+func TestTokeniser_KeepsHexStringDigits(t *testing.T) {
+	tok, err := tokeniser.NewTokeniser(strings.NewReader("<48 65 6c\n6C>")).NextToken()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if string(tok.Raw) != "48 65 6c\n6C" {
+		t.Errorf("Expected the digits as written, got %q", tok.Raw)
+	}
+}
+
+func TestTokeniser_ReturnsErrorForInvalidHexString(t *testing.T) {
+	for _, input := range []string{"<48656C6G>", "<4865"} {
+		tok := tokeniser.NewTokeniser(strings.NewReader(input))
+
+		if _, err := tok.NextToken(); !errors.Is(err, tokeniser.ErrInvalidHexString) {
+			t.Errorf("Expected ErrInvalidHexString for %q, got %v", input, err)
+		}
+	}
+}
+
 func TestTokeniser_HandlesComments(t *testing.T) {
 	pdf := strings.NewReader("%This is a comment\n")
 
@@ -215,8 +359,21 @@ func TestTokeniser_HandlesBooleans(t *testing.T) {
 	expectTokens(t, tokeniser, expected)
 }
 
+func TestTokeniser_HandlesNull(t *testing.T) {
+	pdf := strings.NewReader("null nullish")
+
+	tokeniser := tokeniser.NewTokeniser(pdf)
+
+	expected := []token.Token{
+		{Type: token.NULL},
+		{Type: token.KEYWORD, Value: "nullish"},
+	}
+
+	expectTokens(t, tokeniser, expected)
+}
+
 func TestTokeniser_HandlesFloat(t *testing.T) {
-	pdf := strings.NewReader("1.0000 1.5 1.738478")
+	pdf := strings.NewReader("1.0000 1.5 1.738478 4. -.002 +.5")
 
 	tokeniser := tokeniser.NewTokeniser(pdf)
 
@@ -224,6 +381,9 @@ func TestTokeniser_HandlesFloat(t *testing.T) {
 		{Type: token.NUMBER_FLOAT, Value: float64(1.0000)},
 		{Type: token.NUMBER_FLOAT, Value: float64(1.5)},
 		{Type: token.NUMBER_FLOAT, Value: float64(1.738478)},
+		{Type: token.NUMBER_FLOAT, Value: float64(4)},
+		{Type: token.NUMBER_FLOAT, Value: float64(-0.002)},
+		{Type: token.NUMBER_FLOAT, Value: float64(0.5)},
 	}
 
 	expectTokens(t, tokeniser, expected)
@@ -243,6 +403,88 @@ func TestTokeniser_HandlesInteger(t *testing.T) {
 	expectTokens(t, tokeniser, expected)
 }
 
+func TestTokeniser_HandlesTrailingWhitespace(t *testing.T) {
+	pdf := strings.NewReader("1 \n\t ")
+
+	tokeniser := tokeniser.NewTokeniser(pdf)
+
+	expected := []token.Token{
+		{Type: token.NUMBER_INTEGER, Value: int64(1)},
+		{Type: token.EOF},
+	}
+
+	expectTokens(t, tokeniser, expected)
+}
+
+func TestTokeniser_HandlesCommentsFollowedByTokens(t *testing.T) {
+	pdf := strings.NewReader("%PDF-1.7\r\n%%EOF")
+
+	tokeniser := tokeniser.NewTokeniser(pdf)
+
+	expected := []token.Token{
+		{Type: token.COMMENT, Value: "PDF-1.7"},
+		{Type: token.COMMENT, Value: "%EOF"},
+		{Type: token.EOF},
+	}
+
+	expectTokens(t, tokeniser, expected)
+}
+
+func TestTokeniser_RecordsOffsets(t *testing.T) {
+	pdf := strings.NewReader("1 0 obj\n<< /A (b) >>")
+
+	tokeniser := tokeniser.NewTokeniser(pdf)
+
+	for _, expected := range []int64{0, 2, 4, 8, 11, 14, 18} {
+		tok, _ := tokeniser.NextToken()
+
+		if tok.Offset != expected {
+			t.Errorf("Expected offset %d for %v, got %d", expected, tok, tok.Offset)
+		}
+	}
+}
+
+func TestTokeniser_ReturnsErrorForUnterminatedString(t *testing.T) {
+	pdf := strings.NewReader("(This is a string")
+
+	tok := tokeniser.NewTokeniser(pdf)
+
+	if _, err := tok.NextToken(); !errors.Is(err, tokeniser.ErrUnterminatedString) {
+		t.Errorf("Expected ErrUnterminatedString, got %v", err)
+	}
+}
+
+func TestTokeniser_ReadsInlineImages(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		length   int64
+		expected string
+	}{
+		{"ID abc EI Q", 3, "abc"},
+		{"ID a EI\nEI Q", 4, "a EI"},
+		{"ID abcEIdef\r\nEI Q", -1, "abcEIdef\r"},
+		{"ID abc EI Q", 10, "abc"},
+	} {
+		tok := tokeniser.NewTokeniser(strings.NewReader(test.input)).(*tokeniser.StreamTokeniser)
+		tok.NextToken()
+
+		image, err := tok.ReadInlineImage(test.length)
+
+		if err != nil || string(image.Value.([]byte)) != test.expected {
+			t.Errorf("%q: expected %q, got %q (%v)", test.input, test.expected, image.Value, err)
+		}
+
+		expectTokens(t, tok, []token.Token{{Type: token.KEYWORD, Value: "Q"}})
+	}
+
+	tok := tokeniser.NewTokeniser(strings.NewReader("ID abcEI")).(*tokeniser.StreamTokeniser)
+	tok.NextToken()
+
+	if _, err := tok.ReadInlineImage(-1); !errors.Is(err, tokeniser.ErrUnterminatedImage) {
+		t.Errorf("Expected ErrUnterminatedImage, got %v", err)
+	}
+}
+
 func expectTokens(t *testing.T, tok tokeniser.Tokeniser, expected []token.Token) {
 	for _, expectedToken := range expected {
 		actual, err := tok.NextToken()
@@ -255,7 +497,7 @@ func expectTokens(t *testing.T, tok tokeniser.Tokeniser, expected []token.Token)
 			t.Errorf("Expected token type %v, got %v", expectedToken.Type, actual.Type)
 		}
 
-		if expectedToken.Value != actual.Value {
+		if !reflect.DeepEqual(expectedToken.Value, actual.Value) {
 			t.Errorf("Expected token value \"%v\", got \"%v\"", expectedToken.Value, actual.Value)
 		}
 	}